@@ -0,0 +1,172 @@
+package sol
+
+import (
+	"fmt"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Returns the precedence of a binary arithmetic operator, from loosest (the
+// comma operator) to tightest (exponentiation).
+func arithmPrec(op syntax.BinAritOperator) int {
+	switch op {
+	case syntax.Comma:
+		return 0
+	case syntax.Assgn, syntax.AddAssgn, syntax.SubAssgn, syntax.MulAssgn,
+		syntax.QuoAssgn, syntax.RemAssgn, syntax.AndAssgn, syntax.OrAssgn,
+		syntax.XorAssgn, syntax.ShlAssgn, syntax.ShrAssgn:
+		return 1
+	case syntax.TernQuest, syntax.TernColon:
+		return 2
+	case syntax.OrArit:
+		return 3
+	case syntax.AndArit:
+		return 4
+	case syntax.Or:
+		return 5
+	case syntax.Xor:
+		return 6
+	case syntax.And:
+		return 7
+	case syntax.Eql, syntax.Neq:
+		return 8
+	case syntax.Lss, syntax.Gtr, syntax.Leq, syntax.Geq:
+		return 9
+	case syntax.Shl, syntax.Shr:
+		return 10
+	case syntax.Add, syntax.Sub:
+		return 11
+	case syntax.Mul, syntax.Quo, syntax.Rem:
+		return 12
+	}
+	return 13
+}
+
+// Returns the offsets just past each top-level operator of an arithmetic
+// expression, which is where we'll break lines. Since the operators stay in
+// place, evaluation order doesn't change.
+func arithmBreaks(expr syntax.ArithmExpr) []int {
+	brks := []int{}
+	b, ok := expr.(*syntax.BinaryArithm)
+	if !ok {
+		return brks
+	}
+	opEnd := int(b.OpPos.Offset()) + len(b.Op.String())
+
+	switch prec := arithmPrec(b.Op); {
+
+	// For an assignment, we break up the value being assigned instead.
+	case prec == 1:
+		return arithmBreaks(b.Y)
+
+	// A ternary's `?` and `:` are separate nodes.
+	case b.Op == syntax.TernQuest:
+		brks = append(brks, opEnd)
+		if colon, ok := b.Y.(*syntax.BinaryArithm); ok && colon.Op == syntax.TernColon {
+			brks = append(brks, int(colon.OpPos.Offset())+1)
+		}
+
+	// Exponentiation is right-associative, so there's no chain to follow.
+	case b.Op == syntax.Pow:
+		brks = append(brks, opEnd)
+
+	// Other operators are left-associative, so `a + b - c` is a chain of
+	// operators with the same precedence down the left-hand side.
+	default:
+		if x, ok := b.X.(*syntax.BinaryArithm); ok && arithmPrec(x.Op) == prec {
+			brks = append(brks, arithmBreaks(x)...)
+		}
+		brks = append(brks, opEnd)
+	}
+
+	return brks
+}
+
+// C-style for loop headers longer than this (e.g., `((i = 0, j = ${#a[@]} -
+// 1; i < j; i++, j--))`) are broken at their semicolons. Shorter ones, like
+// `((i = 0; i < n; i++))`, read fine on one line.
+const arithmForMaxLen = 40
+
+// Breaks arithmetic expressions (`$(( ))`, `(( ))`, and quoted `let`
+// expressions) at their top-level operators, and long C-style for loop headers
+// at their semicolons. The shell printer always puts arithmetic on a single
+// line (though it does normalize spacing), so this has to happen after the
+// program is printed.
+func explodeArithm(src string) (string, error) {
+
+	pp, err := parseProg(src)
+	if err != nil {
+		return "", fmt.Errorf("could not parse program: %w", err)
+	}
+
+	chgs := []change{}
+	brk := func(node syntax.Node, offs []int) {
+		idtStr := strings.Repeat(" ", lineIndent(src, node.Pos())+4)
+		for _, off := range offs {
+
+			// The printer always puts a single space after an operator.
+			if off < len(src) && src[off] == ' ' {
+				chgs = append(chgs, change{off, off + 1, "\n" + idtStr})
+			}
+		}
+	}
+
+	syntax.Walk(pp, func(node syntax.Node) bool {
+		switch x := node.(type) {
+
+		case *syntax.ArithmExp:
+			brk(x, arithmBreaks(x.X))
+			return false
+
+		case *syntax.ArithmCmd:
+			brk(x, arithmBreaks(x.X))
+			return false
+
+		case *syntax.LetClause:
+
+			// Unquoted expressions can't span lines, but quoted ones (e.g.,
+			// `let 'x = y * 2'`) can, once we parse them ourselves.
+			for _, expr := range x.Exprs {
+				word, ok := expr.(*syntax.Word)
+				if !ok || len(word.Parts) != 1 {
+					continue
+				}
+				val, ok := wordVal(word)
+				if !ok {
+					continue
+				}
+				valExpr, err := syntax.NewParser().Arithmetic(strings.NewReader(val))
+				if err != nil {
+					continue
+				}
+				start := int(word.Pos().Offset()) + 1
+				offs := []int{}
+				for _, off := range arithmBreaks(valExpr) {
+					offs = append(offs, start+off)
+				}
+				brk(x, offs)
+			}
+			return false
+
+		case *syntax.ForClause:
+			loop, ok := x.Loop.(*syntax.CStyleLoop)
+			if !ok || int(loop.Rparen.Offset()-loop.Lparen.Offset())+2 <= arithmForMaxLen {
+				break
+			}
+			offs := []int{}
+			semi := int(loop.Lparen.Offset()) + 2
+			for _, expr := range []syntax.ArithmExpr{loop.Init, loop.Cond} {
+				if expr != nil {
+					semi = int(expr.End().Offset())
+				}
+				semi += strings.IndexByte(src[semi:], ';') + 1
+				offs = append(offs, semi)
+			}
+			brk(x, offs)
+		}
+		return true
+	})
+
+	return modProg(src, chgs), nil
+}
@@ -0,0 +1,112 @@
+package sol
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+type bashBackend struct{}
+
+// Returns the files that an interactive (non-login) bash shell sources.
+func bashInteractiveRcFiles() []string {
+	files := []string{"/etc/bash.bashrc"}
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, ".bashrc"))
+	}
+	return files
+}
+
+func (bashBackend) RcFiles() []string {
+	files := []string{"/etc/bash.bashrc", "/etc/profile"}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return files
+	}
+	for _, name := range []string{".bashrc", ".bash_profile", ".bash_login", ".profile"} {
+		files = append(files, filepath.Join(home, name))
+	}
+	return files
+}
+
+func (bashBackend) Env() (*ShellEnv, error) {
+	env := &ShellEnv{}
+
+	// Aliases, functions, etc. come from an interactive shell, which sources
+	// the user's dotfiles, while variables come from a non-interactive one.
+	// Both can be slow, so we run them at the same time.
+	var secs, varSecs map[string]string
+	captures := []struct {
+		name string
+		run  func() error
+	}{
+		{"interactive shell env", func() (err error) {
+			secs, err = captureEnv(envShellCmd{
+				Shell:   "bash",
+				Args:    []string{"--norc", "-ic"},
+				RcFiles: bashInteractiveRcFiles(),
+			}, []envSection{
+				{"aliases", "compgen -a"},
+				{"alias-defs", "alias"},
+				{"builtins", "compgen -b"},
+				{"funcs", "compgen -A function"},
+				{"func-defs", "declare -f"},
+				{"keywords", "compgen -k"},
+				{"vars", "compgen -v"},
+			})
+			return err
+		}},
+		{"var defs", func() (err error) {
+			varSecs, err = captureEnv(envShellCmd{Shell: "bash", Args: []string{"-c"}}, []envSection{
+				{"var-defs", "set -o posix; set"},
+			})
+			return err
+		}},
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(captures))
+	for c, capture := range captures {
+		wg.Add(1)
+		go func(c int, name string, run func() error) {
+			defer wg.Done()
+			if err := run(); err != nil {
+				errs[c] = fmt.Errorf("could not get %s: %w", name, err)
+			}
+		}(c, capture.name, capture.run)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	env.Aliases = envList(secs["aliases"])
+	env.Builtins = envList(secs["builtins"])
+	env.Funcs = envList(secs["funcs"])
+	env.Keywords = envList(secs["keywords"])
+	env.Vars = envList(secs["vars"])
+
+	var err error
+	if env.AliasDefs, err = getAliasDefs(secs["alias-defs"]); err != nil {
+		errs = append(errs, err)
+	}
+	if env.FuncDefs, err = getFuncDefs(secs["func-defs"]); err != nil {
+		errs = append(errs, err)
+	}
+	if env.VarDefs, err = getVarDefs(varSecs["var-defs"]); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	// Extract a few important items.
+	if path, ok := env.VarDefs["PATH"]; ok {
+		env.Paths = strings.Split(path, ":")
+	}
+
+	return env, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/noperator/sol"
+	log "github.com/sirupsen/logrus"
+)
+
+const envUsage = `Usage of sol env:
+  sol env export [options] > env.json
+    	save the shell environment, to format against it elsewhere with -env-file
+    	(variables are saved by name only, unless -values is given)
+  sol env diff [options] a.json [b.json] < one-liner.sh
+    	show the commands in a one-liner that resolve differently in two
+    	environments (or in one and the current shell)`
+
+// Handles `sol env`, which saves and compares shell environments.
+func envMain(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, envUsage)
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("sol env "+args[0], flag.ExitOnError)
+	envShell := fs.String("shell", "", "shell whose environment to inspect: bash, fish, ksh, mksh, zsh (default: $SHELL)")
+	envTimeout := fs.Duration("env-timeout", 10*time.Second, "how long the shell gets to print its environment")
+	noEnvCache := fs.Bool("no-env-cache", false, "don't cache the shell environment")
+	refreshEnv := fs.Bool("refresh-env", false, "recapture the shell environment even if it's cached")
+	values := fs.Bool("values", false, "export the values of variables, too (they might be secrets)")
+	file := fs.String("f", "", "file")
+	verbose := fs.Bool("v", false, "verbose")
+	fs.Parse(args[1:])
+
+	if *verbose {
+		log.SetLevel(log.DebugLevel)
+	}
+	if *file == "" {
+		*file = "/dev/stdin"
+	}
+	sol.Cfg = sol.SolCfg{
+		EnvTimeout: *envTimeout,
+		NoEnvCache: *noEnvCache,
+		RefreshEnv: *refreshEnv,
+		Shell:      *envShell,
+	}
+
+	switch args[0] {
+
+	case "export":
+
+		// The cache doesn't keep the values of variables.
+		if *values {
+			sol.Cfg.NoEnvCache = true
+		}
+		env, err := sol.GetShellEnv()
+		if err != nil {
+			log.Fatalf("could not get shell environment: %v", err)
+		}
+		if !*values {
+			env = env.WithoutVarValues()
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(env); err != nil {
+			log.Fatalf("could not export shell environment: %v", err)
+		}
+
+	case "diff":
+		if fs.NArg() < 1 || fs.NArg() > 2 {
+			fmt.Fprintln(os.Stderr, envUsage)
+			os.Exit(2)
+		}
+
+		// Compare against the current shell if there's only one snapshot.
+		names := fs.Args()
+		envs := []*sol.ShellEnv{}
+		for _, name := range names {
+			p, err := sol.LoadStaticEnvProvider(name)
+			if err != nil {
+				log.Fatalf("could not load shell environment: %v", err)
+			}
+			envs = append(envs, &p.Env)
+		}
+		if len(envs) == 1 {
+			env, err := sol.GetShellEnv()
+			if err != nil {
+				log.Fatalf("could not get shell environment: %v", err)
+			}
+			names = append(names, "current shell")
+			envs = append(envs, env)
+		}
+
+		srcBytes, err := os.ReadFile(*file)
+		if err != nil {
+			log.Fatalf("could not read file: %v", err)
+		}
+		diffs, err := sol.DiffShellEnvs(string(srcBytes), envs[0], envs[1])
+		if err != nil {
+			log.Fatalf("could not diff shell environments: %v", err)
+		}
+		for _, d := range diffs {
+			fmt.Printf("%s\n    %s: %s\n    %s: %s\n", d.Cmd, names[0], d.A, names[1], d.B)
+		}
+
+		// Like diff(1), exit with 1 if there are differences.
+		if len(diffs) > 0 {
+			os.Exit(1)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, envUsage)
+		os.Exit(2)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/noperator/jqfmt"
 	"github.com/noperator/sol"
@@ -13,17 +14,42 @@ import (
 
 func main() {
 
+	if len(os.Args) > 1 && os.Args[1] == "env" {
+		envMain(os.Args[2:])
+		os.Exit(0)
+	}
+
 	all := flag.Bool("all", false, "all")
 	args := flag.Bool("a", false, "arguments")
+	arithm := flag.Bool("m", false, "arithmetic: $(( )), (( )), let, for (( ))")
+	assigns := flag.Bool("d", false, "assignments: arrays, declare, export, local")
 	binCmd := flag.Bool("b", false, "binary commands: &&, ||, |, |&")
 	clause := flag.Bool("l", false, "clauses: case, for, if, while")
 	cmdSubst := flag.Bool("c", false, "command substitution: $(), ````")
+	curl := flag.Bool("curl", false, "curl: options, headers, JSON bodies, URLs")
+	envVars := flag.Bool("i", false, "inline environment assignments: FOO=1 cmd")
+	explain := flag.Bool("explain", false, "explain parameter expansions: ${x:-y}, ${x//a/b}, ${#x}")
+	ffmpeg := flag.Bool("ffmpeg", false, "ffmpeg: input/output groups, filtergraphs")
+	groups := flag.Bool("g", false, "groups: ( ), { }, functions")
 	jq := flag.Bool("j", false, "jq")
+	longCmts := flag.Bool("longcmt", false, "long options: annotate short options with their long forms")
+	longOpts := flag.Bool("long", false, "long options: rewrite short options into their long forms")
+	mlr := flag.Bool("mlr", false, "Miller: verb chains, put/filter expressions")
+	parallel := flag.Bool("parallel", false, "GNU parallel: options, command template, input sources, replacement strings")
 	procSubst := flag.Bool("p", false, "process substitution: <(), >()")
 	redir := flag.Bool("r", false, "redirect: >, >>, <, <>, <&, >&, >|, <<, <<-, <<<, &>, &>>")
+	shortOpts := flag.Bool("short", false, "short options: collapse long options into clustered short forms")
 	shell := flag.Bool("s", false, "shell strings: xargs, parallel")
+	test := flag.Bool("x", false, "test expressions: [[ ]], [ ], test")
+	tmpl := flag.Bool("t", false, "templates: Go templates (docker, kubectl, gh)")
 
 	env := flag.Bool("e", false, "inspect env to resolve command types")
+	envFile := flag.String("env-file", "", "resolve command types against an environment `file` saved with sol env export")
+	envTimeout := flag.Duration("env-timeout", 10*time.Second, "how long the shell gets to print its environment")
+	noEnvCache := flag.Bool("no-env-cache", false, "don't cache the shell environment")
+	refreshEnv := flag.Bool("refresh-env", false, "recapture the shell environment even if it's cached")
+	envShell := flag.String("shell", "", "shell whose environment to inspect: bash, fish, ksh, mksh, zsh (default: $SHELL)")
+	optSpecs := flag.String("specs", "", "option specs file (default: <config dir>/sol/opt-specs.txt)")
 	oneLine := flag.Bool("o", false, "one line")
 	// jqFuncsStr := flag.String("jf", "group_by,select,sort_by,map", "jq functions")
 	file := flag.String("f", "", "file")
@@ -46,6 +72,10 @@ func main() {
 		*jq = true
 	}
 
+	if *envFile != "" {
+		*env = true
+	}
+
 	if *verbose {
 		log.SetLevel(log.DebugLevel)
 	}
@@ -83,42 +113,93 @@ func main() {
 	// TODO: Might need to touch this up.
 	if *all {
 		*args = true
+		*assigns = true
+		*arithm = true
 		*binCmd = true
 		*clause = true
 		*cmdSubst = true
+		*curl = true
+		*envVars = true
+		*explain = true
+		*ffmpeg = true
+		*groups = true
 		*jq = true
+		*mlr = true
+		*parallel = true
 		*procSubst = true
 		*redir = true
 		*shell = true
+		*test = true
+		*tmpl = true
 		*env = true
 	} else if *oneLine {
 		*args = false
+		*assigns = false
+		*arithm = false
 		*binCmd = false
 		*clause = false
 		*cmdSubst = false
+		*curl = false
+		*envVars = false
+		*explain = false
+		*ffmpeg = false
+		*groups = false
+		*longCmts = false
+		*longOpts = false
 		*jq = false
+		*mlr = false
+		*parallel = false
 		*procSubst = false
 		*redir = false
 		*shell = false
-	} else if !(*args || *binCmd || *clause || *cmdSubst || *jq || *procSubst || *redir || *shell) {
+		*test = false
+		*tmpl = false
+	} else if !(*args || *arithm || *assigns || *binCmd || *clause || *cmdSubst || *curl || *envVars || *explain || *ffmpeg || *groups || *jq || *longCmts || *longOpts || *mlr || *parallel || *procSubst || *redir || *shell || *test || *tmpl) {
 		*binCmd = true
 	}
 
 	// jqFuncs := strings.Split(*jqFuncsStr, ",")
 
 	sol.Cfg = sol.SolCfg{
-		Args:      *args,
-		BinCmd:    *binCmd,
-		CmdSubst:  *cmdSubst,
-		ProcSubst: *procSubst,
-		Sh:        *shell,
-		Jq:        *jq,
-		Clause:    *clause,
-		Redir:     *redir,
-		JqFmtCfg:  jqFmtCfg,
-		OneLine:   *oneLine,
-		Env:       *env,
-		MaxWidth:  *maxWidth,
+		Args:       *args,
+		Arithm:     *arithm,
+		Assigns:    *assigns,
+		BinCmd:     *binCmd,
+		CmdSubst:   *cmdSubst,
+		Curl:       *curl,
+		EnvVars:    *envVars,
+		Explain:    *explain,
+		Ffmpeg:     *ffmpeg,
+		Groups:     *groups,
+		LongCmts:   *longCmts,
+		LongOpts:   *longOpts,
+		Mlr:        *mlr,
+		Parallel:   *parallel,
+		ProcSubst:  *procSubst,
+		Sh:         *shell,
+		Shell:      *envShell,
+		ShortOpts:  *shortOpts,
+		Jq:         *jq,
+		Tmpl:       *tmpl,
+		Clause:     *clause,
+		Redir:      *redir,
+		Test:       *test,
+		JqFmtCfg:   jqFmtCfg,
+		OneLine:    *oneLine,
+		OptSpecs:   *optSpecs,
+		Env:        *env,
+		EnvTimeout: *envTimeout,
+		NoEnvCache: *noEnvCache,
+		RefreshEnv: *refreshEnv,
+		MaxWidth:   *maxWidth,
+	}
+
+	if *envFile != "" {
+		p, err := sol.LoadStaticEnvProvider(*envFile)
+		if err != nil {
+			log.Fatalf("could not load shell environment: %v", err)
+		}
+		sol.Cfg.EnvProvider = p
 	}
 
 	log.Debugf("cfg: %+v\n", sol.Cfg)
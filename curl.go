@@ -0,0 +1,236 @@
+package sol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Short curl options that take a value (e.g., `-H 'Accept: */*'`).
+var curlValOpts = "ACDEFHKPQTUXYbcdemortuwxyz"
+
+// Long curl options that take a value. Options not listed here are assumed
+// to be boolean flags.
+var curlLongValOpts = []string{
+	"--aws-sigv4",
+	"--cacert", "--capath", "--cert", "--cert-type", "--ciphers",
+	"--config",
+	"--connect-timeout", "--connect-to",
+	"--continue-at",
+	"--cookie", "--cookie-jar",
+	"--data", "--data-ascii", "--data-binary", "--data-raw", "--data-urlencode",
+	"--dump-header",
+	"--form", "--form-string",
+	"--ftp-port",
+	"--header", "--proxy-header",
+	"--interface",
+	"--json",
+	"--key", "--key-type",
+	"--limit-rate",
+	"--max-filesize", "--max-redirs", "--max-time",
+	"--noproxy",
+	"--oauth2-bearer",
+	"--output", "--output-dir",
+	"--pinnedpubkey",
+	"--proto", "--proto-redir",
+	"--proxy", "--proxy-user",
+	"--quote",
+	"--range",
+	"--referer",
+	"--request",
+	"--resolve",
+	"--retry", "--retry-delay", "--retry-max-time",
+	"--speed-limit", "--speed-time",
+	"--stderr",
+	"--telnet-option",
+	"--time-cond",
+	"--trace", "--trace-ascii",
+	"--upload-file",
+	"--url",
+	"--user", "--user-agent",
+	"--variable",
+	"--write-out",
+}
+
+// Options whose value is a request body.
+var curlDataOpts = []string{"-d", "--data", "--data-ascii", "--data-binary", "--data-raw", "--json"}
+
+// The arguments of a curl invocation. Each value is an index into the
+// command's arguments.
+type curlArgs struct {
+	Opts []int // start of each option (and its value, if any)
+	URLs []int // each positional argument (i.e., a URL)
+	Data []int // each request body
+}
+
+func parseCurl(x *syntax.CallExpr) curlArgs {
+	ca := curlArgs{}
+	for a := 1; a < len(x.Args); a++ {
+		val, _ := wordVal(x.Args[a])
+		if !strings.HasPrefix(val, "-") || val == "-" {
+			ca.URLs = append(ca.URLs, a)
+			continue
+		}
+		ca.Opts = append(ca.Opts, a)
+
+		// Long options, e.g., `--header 'Accept: */*'`.
+		if strings.HasPrefix(val, "--") {
+			if strings.Contains(val, "=") {
+				continue
+			}
+			for _, opt := range curlLongValOpts {
+				if val == opt && a+1 < len(x.Args) {
+					a++
+					break
+				}
+			}
+			for _, opt := range curlDataOpts {
+				if val == opt {
+					ca.Data = append(ca.Data, a)
+				}
+			}
+			continue
+		}
+
+		// Clustered short options, e.g., `-sSLo out.html` or `-XPOST`.
+		opts := val[1:]
+		for o := 0; o < len(opts); o++ {
+			if strings.IndexByte(curlValOpts, opts[o]) < 0 {
+				continue
+			}
+			if o == len(opts)-1 && a+1 < len(x.Args) {
+				a++
+				if opts[o] == 'd' {
+					ca.Data = append(ca.Data, a)
+				}
+			}
+			break
+		}
+	}
+	return ca
+}
+
+// Returns changes that put each curl option (along with its value) and each
+// URL on its own line.
+func fmtCurlArgs(x *syntax.CallExpr) []change {
+	brks := []int{}
+	if len(x.Args) == 0 || getCmdVal(*x) != "curl" {
+		return breakArgs(x, brks)
+	}
+	ca := parseCurl(x)
+	brks = append(brks, ca.Opts...)
+	brks = append(brks, ca.URLs...)
+	return breakArgs(x, brks)
+}
+
+// Returns the value of a quoted request body, along with a function that
+// quotes a new value the same way. Only single-quoted strings and
+// double-quoted strings without expansions (e.g., `-d "{\"a\": 1}"` but not
+// `-d "$(cat body.json)"`) have a value that's known ahead of time.
+func curlBody(w *syntax.Word) (string, func(string) string, bool) {
+	if len(w.Parts) != 1 {
+		return "", nil, false
+	}
+	switch p := w.Parts[0].(type) {
+	case *syntax.SglQuoted:
+		if p.Dollar {
+			return "", nil, false
+		}
+		return p.Value, func(s string) string { return s }, true
+	case *syntax.DblQuoted:
+		if p.Dollar || len(p.Parts) != 1 {
+			return "", nil, false
+		}
+		lit, ok := p.Parts[0].(*syntax.Lit)
+		if !ok {
+			return "", nil, false
+		}
+		return dblUnquote(lit.Value), dblQuote, true
+	}
+	return "", nil, false
+}
+
+// Returns the value of the text inside a double-quoted string, where a
+// backslash only escapes `$`, a backtick, `"`, `\`, or a line break.
+func dblUnquote(s string) string {
+	var val strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0 {
+			i++
+			if s[i] == '\n' {
+				continue
+			}
+		}
+		val.WriteByte(s[i])
+	}
+	return val.String()
+}
+
+// Reverses `dblUnquote`. A backslash that doesn't need escaping is left as it
+// is (e.g., the one in `\n`).
+func dblQuote(val string) string {
+	var s strings.Builder
+	for i := 0; i < len(val); i++ {
+		c := val[i]
+		switch {
+		case c == '$' || c == '`' || c == '"':
+			s.WriteByte('\\')
+		case c == '\\' && (i+1 == len(val) || strings.IndexByte("$`\"\\\n", val[i+1]) >= 0):
+			s.WriteByte('\\')
+		}
+		s.WriteByte(c)
+	}
+	return s.String()
+}
+
+// Returns changes that pretty-print quoted JSON request bodies (or, if
+// imploding, minify them).
+func fmtCurl(x *syntax.CallExpr, implode bool, src string) ([]change, error) {
+
+	chgs := []change{}
+	if getCmdVal(*x) != "curl" {
+		return chgs, nil
+	}
+
+	for _, d := range parseCurl(x).Data {
+		val, quote, ok := curlBody(x.Args[d])
+		if !ok {
+			continue
+		}
+		part := x.Args[d].Parts[0]
+		body := strings.TrimSpace(val)
+		if !(strings.HasPrefix(body, "{") || strings.HasPrefix(body, "[")) || !json.Valid([]byte(body)) {
+			continue
+		}
+
+		var buf bytes.Buffer
+		bodyMod := ""
+		if implode {
+			if err := json.Compact(&buf, []byte(body)); err != nil {
+				return chgs, fmt.Errorf("could not minify json: %w", err)
+			}
+			bodyMod = buf.String()
+		} else {
+			if err := json.Indent(&buf, []byte(body), "", "    "); err != nil {
+				return chgs, fmt.Errorf("could not indent json: %w", err)
+			}
+			var err error
+			bodyMod, err = indent(buf.String(), lineIndent(src, part.Pos())+4, true)
+			if err != nil {
+				return chgs, fmt.Errorf("could not indent json: %w", err)
+			}
+		}
+		if bodyMod == val {
+			continue
+		}
+		bodyMod = quote(bodyMod)
+		pos := int(part.Pos().Offset())
+		end := int(part.End().Offset())
+		chgs = append(chgs, change{pos + 1, end - 1, bodyMod})
+	}
+
+	return chgs, nil
+}
@@ -0,0 +1,200 @@
+package sol
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Bump this whenever `ShellEnv` (or what we keep of it) changes so that old
+// caches are ignored.
+const envCacheVersion = 4
+
+// A captured shell environment, along with what it was captured from. The
+// cache is stale once $PATH or the modification time of any file it depends
+// on (dotfiles, the files that they source, and the directories in $PATH)
+// changes. Nonexistent files have a zero time, so creating one invalidates
+// the cache, too. The values of variables other than PATH aren't kept.
+type envCache struct {
+	Version int
+	Path    string
+	Mtimes  map[string]time.Time
+	Env     *ShellEnv
+}
+
+// Returns the path of a shell's environment cache (e.g.,
+// `~/.cache/sol/bash-env.json` on Linux).
+func envCacheFile(shell string) string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(cacheDir, "sol", shell+"-env.json")
+}
+
+// Returns the files that a dotfile sources (e.g., `. ~/.bash_aliases`), and
+// the ones that those source, and so on. Only paths that we can expand
+// without running anything are followed.
+func sourcedFiles(path string, seen map[string]bool) []string {
+	if seen[path] {
+		return nil
+	}
+	seen[path] = true
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	prog, err := syntax.NewParser().Parse(strings.NewReader(string(src)), path)
+	if err != nil {
+		log.Debugf("could not parse %s: %v", path, err)
+		return nil
+	}
+
+	home, _ := os.UserHomeDir()
+	cfg := &expand.Config{Env: expand.ListEnviron(os.Environ()...)}
+	files := []string{}
+	syntax.Walk(prog, func(node syntax.Node) bool {
+		x, ok := node.(*syntax.CallExpr)
+		if !ok || len(x.Args) < 2 {
+			return true
+		}
+		if cmd, _ := wordVal(x.Args[0]); cmd != "source" && cmd != "." {
+			return true
+		}
+		file, err := expand.Literal(cfg, x.Args[1])
+		if err != nil || file == "" {
+			return true
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(home, file)
+		}
+		files = append(files, file)
+		files = append(files, sourcedFiles(file, seen)...)
+		return true
+	})
+	return files
+}
+
+// Returns the modification times of everything that a shell environment
+// depends on.
+func envMtimes(backend envBackend, env *ShellEnv) map[string]time.Time {
+	files := []string{}
+	seen := map[string]bool{}
+	for _, rc := range backend.RcFiles() {
+		files = append(files, rc)
+		files = append(files, sourcedFiles(rc, seen)...)
+	}
+	files = append(files, env.Paths...)
+
+	mtimes := map[string]time.Time{}
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		mtimes[file] = time.Time{}
+		if info, err := os.Stat(file); err == nil {
+			mtimes[file] = info.ModTime()
+		}
+	}
+	return mtimes
+}
+
+// Returns the cached shell environment, or false if there isn't one or it's
+// stale.
+func readEnvCache(path string) (*ShellEnv, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warningf("could not read env cache: %v", err)
+		}
+		return nil, false
+	}
+	var cache envCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		log.Warningf("could not parse env cache: %v", err)
+		return nil, false
+	}
+	if cache.Version != envCacheVersion || cache.Env == nil || cache.Path != os.Getenv("PATH") {
+		return nil, false
+	}
+	for file, mtime := range cache.Mtimes {
+		cur := time.Time{}
+		if info, err := os.Stat(file); err == nil {
+			cur = info.ModTime()
+		}
+		if !cur.Equal(mtime) {
+			log.Debugf("env cache is stale: %s changed", file)
+			return nil, false
+		}
+	}
+	return cache.Env, true
+}
+
+func writeEnvCache(path string, backend envBackend, env *ShellEnv) error {
+	data, err := json.Marshal(envCache{
+		Version: envCacheVersion,
+		Path:    os.Getenv("PATH"),
+		Mtimes:  envMtimes(backend, env),
+		Env:     env.WithoutVarValues(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	// Write to a temporary file (which only the user can read) first so that
+	// a concurrent run never reads a partial cache.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".shell-env-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Returns the shell environment, from the cache if it's still fresh.
+// Capturing it means starting several interactive shells, which is too slow
+// to do on every keypress (e.g., with the readline binding in the README).
+// Variables that are exported after the environment was cached aren't
+// noticed until it's refreshed.
+func getCachedShellEnv() (*ShellEnv, error) {
+	shell, err := envShell()
+	if err != nil {
+		return nil, err
+	}
+	backend := envBackends[shell]
+	path := envCacheFile(shell)
+	if Cfg.NoEnvCache || path == "" {
+		return backend.Env()
+	}
+	if !Cfg.RefreshEnv {
+		if env, ok := readEnvCache(path); ok {
+			log.Debugln("using env cache", path)
+			return env, nil
+		}
+	}
+
+	env, err := backend.Env()
+	if err != nil {
+		return nil, err
+	}
+	if err := writeEnvCache(path, backend, env); err != nil {
+		log.Warningf("could not write env cache %s: %v", path, err)
+	}
+	return env, nil
+}
@@ -0,0 +1,227 @@
+package sol
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// How long a shell gets to print its environment if `Cfg.EnvTimeout` isn't
+// set.
+const defaultEnvTimeout = 10 * time.Second
+
+// A piece of the shell environment and the command that prints it, e.g.,
+// `compgen -a` for aliases.
+type envSection struct {
+	Name string
+	Cmd  string
+}
+
+// Terminal control sequences (e.g., the ones that `tabs -4` prints) and other
+// control characters besides tabs and newlines.
+var ctrlSeqRe = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[ -/]*[0-~]|[\x00-\x08\x0b-\x1f\x7f]`)
+
+func stripCtrlSeqs(s string) string {
+	return ctrlSeqRe.ReplaceAllString(s, "")
+}
+
+// Returns a marker that nothing in the user's dotfiles will print by accident.
+func newEnvMarker() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "SOL-" + hex.EncodeToString(b), nil
+}
+
+// How to run a shell to capture its environment.
+type envShellCmd struct {
+	Shell   string
+	Args    []string // followed by the capture script, e.g., `-ic`
+	Environ []string // added to our own environment
+	RcFiles []string // dotfiles that the capture script sources itself
+	Startup string   // dotfiles that the shell sources on its own, if any
+	Fish    bool     // whether the capture script is in fish syntax
+}
+
+// Runs a shell that sources its dotfiles and then prints each section of its
+// environment to file descriptor 3, between markers. Anything that the
+// dotfiles print (banners, escape codes, etc.) goes to stdout or stderr
+// instead, where it can't corrupt the sections, and is reported along with the
+// dotfile that printed it. Returns the output of each section by name.
+func captureEnv(sh envShellCmd, sections []envSection) (map[string]string, error) {
+	marker, err := newEnvMarker()
+	if err != nil {
+		return nil, fmt.Errorf("could not create marker: %w", err)
+	}
+
+	// Mark where each dotfile starts in stdout and stderr so that we can tell
+	// which one printed what.
+	var script strings.Builder
+	mark := func(s string) {
+		fmt.Fprintf(&script, "printf '\\n%%s\\n' '%s %s'; printf '\\n%%s\\n' '%s %s' >&2\n", marker, s, marker, s)
+	}
+	for _, rc := range sh.RcFiles {
+		rcQuoted, err := syntax.Quote(rc, syntax.LangBash)
+		if err != nil {
+			return nil, fmt.Errorf("could not quote %s: %w", rc, err)
+		}
+		mark("rc " + rc)
+		fmt.Fprintf(&script, "[ -r %s ] && . %s\n", rcQuoted, rcQuoted)
+	}
+	mark("done")
+	status := "$?"
+	if sh.Fish {
+		status = "$status"
+	}
+	for _, sec := range sections {
+		fmt.Fprintf(&script, "printf '%%s\\n' '%s begin %s' >&3\n", marker, sec.Name)
+		fmt.Fprintf(&script, "%s >&3\n", sec.Cmd)
+		fmt.Fprintf(&script, "printf '%%s %%d\\n' '%s end %s' %s >&3\n", marker, sec.Name, status)
+	}
+
+	// The sections go to a file rather than a pipe, so that a background
+	// process started by a dotfile can't keep us waiting on it.
+	out, err := os.CreateTemp("", "sol-env-*")
+	if err != nil {
+		return nil, fmt.Errorf("could not create capture file: %w", err)
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	timeout := Cfg.EnvTimeout
+	if timeout <= 0 {
+		timeout = defaultEnvTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, sh.Shell, append(sh.Args, script.String())...)
+	cmd.Env = append(os.Environ(), sh.Environ...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.ExtraFiles = []*os.File{out}
+	cmd.WaitDelay = time.Second
+	runErr := cmd.Run()
+	if cmd.ProcessState == nil {
+		return nil, runErr
+	}
+
+	lastRc := reportEnvNoise(marker, sh.Startup, stdout.String(), stderr.String())
+	if ctx.Err() == context.DeadlineExceeded {
+		if lastRc != "" {
+			return nil, fmt.Errorf("%s timed out after %s while sourcing %s", sh.Shell, timeout, lastRc)
+		}
+		return nil, fmt.Errorf("%s timed out after %s", sh.Shell, timeout)
+	}
+
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		return nil, fmt.Errorf("could not read capture file: %w", err)
+	}
+	secs, err := parseEnvSections(marker, string(data), sections)
+	if err != nil {
+		if runErr != nil {
+			err = errors.Join(err, runErr)
+		}
+		if _, msg, ok := strings.Cut(stderr.String(), marker+" done"); ok && strings.TrimSpace(msg) != "" {
+			err = fmt.Errorf("%w: %s", err, strings.TrimSpace(stripCtrlSeqs(msg)))
+		}
+		return nil, err
+	}
+	return secs, nil
+}
+
+// Returns the output of each section, which must all be there. A command
+// that fails without printing anything (e.g., `compgen -a` without any
+// aliases) just has nothing to report.
+func parseEnvSections(marker string, out string, sections []envSection) (map[string]string, error) {
+	secs := map[string]string{}
+	cur := ""
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, marker+" ") {
+			if cur != "" {
+				lines = append(lines, stripCtrlSeqs(line))
+			}
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, marker+" "))
+		switch {
+		case len(fields) == 2 && fields[0] == "begin":
+			cur, lines = fields[1], []string{}
+		case len(fields) == 3 && fields[0] == "end" && fields[1] == cur:
+			status, _ := strconv.Atoi(fields[2])
+			if status != 0 && (status != 1 || len(lines) > 0) {
+				return nil, fmt.Errorf("could not get %s: exit status %d", cur, status)
+			}
+			secs[cur] = strings.Join(lines, "\n")
+			cur = ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for _, sec := range sections {
+		if _, ok := secs[sec.Name]; !ok {
+			return nil, fmt.Errorf("could not get %s: no output", sec.Name)
+		}
+	}
+	return secs, nil
+}
+
+// Warns about anything that a dotfile printed while it was being sourced, and
+// returns the last dotfile that the shell started sourcing. Anything printed
+// before the first marker comes from the dotfiles that the shell sources on
+// its own (`startup`), if any.
+func reportEnvNoise(marker string, startup string, outs ...string) string {
+	noise := map[string][]string{}
+	rcs := []string{}
+	if startup != "" {
+		noise[startup] = []string{}
+		rcs = append(rcs, startup)
+	}
+	lastRc := startup
+	for _, out := range outs {
+		rc := startup
+		for _, line := range strings.Split(out, "\n") {
+			if after, ok := strings.CutPrefix(line, marker+" "); ok {
+				rc, _ = strings.CutPrefix(after, "rc ")
+				if rc == "done" {
+					rc = ""
+				} else if _, ok := noise[rc]; !ok {
+					noise[rc] = []string{}
+					rcs = append(rcs, rc)
+				}
+				lastRc = rc
+				continue
+			}
+			if line = strings.TrimSpace(stripCtrlSeqs(line)); rc != "" && line != "" {
+				noise[rc] = append(noise[rc], line)
+			}
+		}
+	}
+	for _, rc := range rcs {
+		if len(noise[rc]) > 0 {
+			log.Warningf("%s printed output while capturing shell environment: %s", rc, strings.Join(noise[rc], " / "))
+		}
+	}
+	return lastRc
+}
@@ -0,0 +1,76 @@
+package sol
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// A command that resolves differently in two shell environments, e.g.,
+// `alias: ls -l` in one and `missing` in the other.
+type CmdDiff struct {
+	Cmd string
+	A   string
+	B   string
+}
+
+// Returns what a command resolves to in a shell environment, on one line.
+func describeCmd(env *ShellEnv, cmd string) string {
+	cmdTypes := resolveCmd(env, cmd)
+	if len(cmdTypes) == 0 {
+		return "missing"
+	}
+	ct := cmdTypes[0]
+	if ct.Def == "" {
+		return ct.Type
+	}
+	return fmt.Sprintf("%s: %s", ct.Type, oneLineDef(ct.Def))
+}
+
+// Returns the commands that a program runs, in the order that they first
+// show up.
+func programCmds(src string) ([]string, error) {
+	prog, err := syntax.NewParser().Parse(strings.NewReader(src), "")
+	if err != nil {
+		return nil, fmt.Errorf("could not parse program: %w", err)
+	}
+	cmds := []string{}
+	seen := map[string]bool{}
+	syntax.Walk(prog, func(node syntax.Node) bool {
+		x, ok := node.(*syntax.CallExpr)
+		if !ok || len(x.Args) == 0 {
+			return true
+		}
+		cmd, ok := wordVal(x.Args[0])
+		if !ok || cmd == "" {
+			return true
+		}
+		cmd = filepath.Base(cmd)
+		if !seen[cmd] {
+			seen[cmd] = true
+			cmds = append(cmds, cmd)
+		}
+		return true
+	})
+	return cmds, nil
+}
+
+// Returns the commands in a program that resolve differently in two shell
+// environments, e.g., an alias that a teammate has but we don't.
+func DiffShellEnvs(src string, a *ShellEnv, b *ShellEnv) ([]CmdDiff, error) {
+	cmds, err := programCmds(src)
+	if err != nil {
+		return nil, err
+	}
+	diffs := []CmdDiff{}
+	for _, cmd := range cmds {
+		descA := describeCmd(a, cmd)
+		descB := describeCmd(b, cmd)
+		if descA != descB {
+			diffs = append(diffs, CmdDiff{cmd, descA, descB})
+		}
+	}
+	return diffs, nil
+}
@@ -0,0 +1,127 @@
+package sol
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Provides the shell environment that commands are resolved against (see
+// `Cfg.Env`).
+type EnvProvider interface {
+	ShellEnv() (*ShellEnv, error)
+}
+
+// Captures the user's own shell environment, or reads it from the cache.
+type shellEnvProvider struct{}
+
+func (shellEnvProvider) ShellEnv() (*ShellEnv, error) {
+	return getCachedShellEnv()
+}
+
+// Returns the shell environment that commands are resolved against, e.g., to
+// export it for someone else to format against.
+func GetShellEnv() (*ShellEnv, error) {
+	return envProvider().ShellEnv()
+}
+
+func envProvider() EnvProvider {
+	if Cfg.EnvProvider != nil {
+		return Cfg.EnvProvider
+	}
+	return shellEnvProvider{}
+}
+
+// Provides a fixed shell environment, e.g., for tests, or to format a
+// one-liner against someone else's environment.
+type StaticEnvProvider struct {
+	Env ShellEnv
+}
+
+// Returns a provider for a fixed shell environment. Only the definitions need
+// to be filled in: names that are missing from the lists (e.g., `Aliases`)
+// are taken from the definitions (e.g., `AliasDefs`), and `Paths` defaults to
+// the PATH variable.
+func NewStaticEnvProvider(env ShellEnv) *StaticEnvProvider {
+	addNames := func(names []string, defs map[string]string) []string {
+		if names == nil {
+			names = []string{}
+		}
+		for _, name := range envNames(defs) {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	if env.AbbrDefs == nil {
+		env.AbbrDefs = map[string]string{}
+	}
+	if env.AliasDefs == nil {
+		env.AliasDefs = map[string]string{}
+	}
+	if env.FuncDefs == nil {
+		env.FuncDefs = map[string]string{}
+	}
+	if env.VarDefs == nil {
+		env.VarDefs = map[string]string{}
+	}
+	env.Abbrs = addNames(env.Abbrs, env.AbbrDefs)
+	env.Aliases = addNames(env.Aliases, env.AliasDefs)
+	env.Funcs = addNames(env.Funcs, env.FuncDefs)
+	env.Vars = addNames(env.Vars, env.VarDefs)
+	if env.Builtins == nil {
+		env.Builtins = []string{}
+	}
+	if env.Keywords == nil {
+		env.Keywords = []string{}
+	}
+	if path, ok := env.VarDefs["PATH"]; ok && env.Paths == nil {
+		env.Paths = strings.Split(path, ":")
+	}
+	return &StaticEnvProvider{Env: env}
+}
+
+// Returns a provider for a shell environment in a JSON or YAML file (by its
+// extension), with the same fields as `ShellEnv`, e.g.:
+//
+//	aliasDefs:
+//	  ll: ls -l
+//	varDefs:
+//	  PATH: /usr/local/bin:/usr/bin:/bin
+func LoadStaticEnvProvider(path string) (*StaticEnvProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read env file: %w", err)
+	}
+
+	// Field names are matched case-insensitively, in YAML, too.
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var v any
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("could not parse env file: %w", err)
+		}
+		if data, err = json.Marshal(v); err != nil {
+			return nil, fmt.Errorf("could not convert env file: %w", err)
+		}
+	case ".json":
+	default:
+		return nil, fmt.Errorf("env file must be JSON or YAML: %s", path)
+	}
+
+	var env ShellEnv
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("could not parse env file: %w", err)
+	}
+	return NewStaticEnvProvider(env), nil
+}
+
+func (p *StaticEnvProvider) ShellEnv() (*ShellEnv, error) {
+	return &p.Env, nil
+}
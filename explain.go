@@ -0,0 +1,157 @@
+package sol
+
+import (
+	"fmt"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Prints a node on a single line, which is how it's best described.
+func singleLine(node syntax.Node) string {
+	var sb strings.Builder
+	syntax.NewPrinter(syntax.SingleLine(true)).Print(&sb, node)
+	return sb.String()
+}
+
+// Returns a description of a parameter expansion, e.g., `x, or y if unset or
+// empty` for `${x:-y}`, or an empty string for a trivial one like `$x`.
+func explainParamExp(pe *syntax.ParamExp, src string) string {
+	nodeStr := func(node syntax.Node) string {
+		return src[node.Pos().Offset():node.End().Offset()]
+	}
+	wordStr := func(w *syntax.Word) string {
+		if w == nil {
+			return ""
+		}
+		return singleLine(w)
+	}
+	name := ""
+	if pe.Param != nil {
+		name = pe.Param.Value
+	}
+	allIdx := false
+	if pe.Index != nil {
+		idx := nodeStr(pe.Index)
+		allIdx = idx == "@" || idx == "*"
+		name = fmt.Sprintf("%s[%s]", name, idx)
+	}
+
+	switch {
+
+	case pe.Length:
+		if allIdx {
+			return fmt.Sprintf("number of elements in %s", pe.Param.Value)
+		}
+		return fmt.Sprintf("length of %s", name)
+
+	case pe.Excl && pe.Names != 0:
+		return fmt.Sprintf("names of variables starting with %s", name)
+
+	case pe.Excl && allIdx:
+		return fmt.Sprintf("keys of %s", pe.Param.Value)
+
+	case pe.Excl:
+		return fmt.Sprintf("value of the variable named by %s", name)
+
+	case pe.Slice != nil:
+		desc := fmt.Sprintf("substring of %s from offset %s", name, nodeStr(pe.Slice.Offset))
+		if pe.Slice.Length != nil {
+			desc += fmt.Sprintf(" of length %s", nodeStr(pe.Slice.Length))
+		}
+		return desc
+
+	case pe.Repl != nil:
+
+		// A slash in the pattern is escaped (e.g., `${x//\//_}`) only so that
+		// it doesn't end the pattern.
+		orig := strings.ReplaceAll(wordStr(pe.Repl.Orig), `\/`, "/")
+		match := "the first substring"
+		if pe.Repl.All {
+			match = "every substring"
+		} else if strings.HasPrefix(orig, "#") {
+			match, orig = "the longest prefix", orig[1:]
+		} else if strings.HasPrefix(orig, "%") {
+			match, orig = "the longest suffix", orig[1:]
+		}
+		if pe.Repl.With == nil {
+			return fmt.Sprintf("%s without %s matching %s", name, match, orig)
+		}
+		return fmt.Sprintf("%s with %s matching %s replaced by %s", name, match, orig, wordStr(pe.Repl.With))
+
+	case pe.Exp != nil:
+		word := wordStr(pe.Exp.Word)
+		switch pe.Exp.Op {
+		case syntax.AlternateUnset:
+			return fmt.Sprintf("%s if %s is set", word, name)
+		case syntax.AlternateUnsetOrNull:
+			return fmt.Sprintf("%s if %s is set and not empty", word, name)
+		case syntax.DefaultUnset:
+			return fmt.Sprintf("%s, or %s if unset", name, word)
+		case syntax.DefaultUnsetOrNull:
+			return fmt.Sprintf("%s, or %s if unset or empty", name, word)
+		case syntax.ErrorUnset:
+			return fmt.Sprintf("%s, or fail with %s if unset", name, word)
+		case syntax.ErrorUnsetOrNull:
+			return fmt.Sprintf("%s, or fail with %s if unset or empty", name, word)
+		case syntax.AssignUnset:
+			return fmt.Sprintf("%s, after setting it to %s if unset", name, word)
+		case syntax.AssignUnsetOrNull:
+			return fmt.Sprintf("%s, after setting it to %s if unset or empty", name, word)
+		case syntax.RemSmallSuffix:
+			return fmt.Sprintf("%s without the shortest suffix matching %s", name, word)
+		case syntax.RemLargeSuffix:
+			return fmt.Sprintf("%s without the longest suffix matching %s", name, word)
+		case syntax.RemSmallPrefix:
+			return fmt.Sprintf("%s without the shortest prefix matching %s", name, word)
+		case syntax.RemLargePrefix:
+			return fmt.Sprintf("%s without the longest prefix matching %s", name, word)
+		case syntax.UpperFirst:
+			return fmt.Sprintf("%s with the first character uppercased", name)
+		case syntax.UpperAll:
+			return fmt.Sprintf("%s uppercased", name)
+		case syntax.LowerFirst:
+			return fmt.Sprintf("%s with the first character lowercased", name)
+		case syntax.LowerAll:
+			return fmt.Sprintf("%s lowercased", name)
+		case syntax.OtherParamOps:
+			switch word {
+			case "Q":
+				return fmt.Sprintf("%s quoted for reuse as shell input", name)
+			case "E":
+				return fmt.Sprintf("%s with backslash escapes expanded", name)
+			case "P":
+				return fmt.Sprintf("%s expanded like a prompt string", name)
+			case "A":
+				return fmt.Sprintf("an assignment that recreates %s", name)
+			case "a":
+				return fmt.Sprintf("attributes of %s", name)
+			case "U":
+				return fmt.Sprintf("%s uppercased", name)
+			case "u":
+				return fmt.Sprintf("%s with the first character uppercased", name)
+			case "L":
+				return fmt.Sprintf("%s lowercased", name)
+			}
+		}
+	}
+
+	return ""
+}
+
+// Puts a comment above each statement that describes its non-trivial
+// parameter expansions (e.g., `${x:-y}`, `${x//a/b}`, or `${#x[@]}`), one per
+// line.
+func explainExps(src string) (string, error) {
+	return commentStmts(src, func(node syntax.Node) []string {
+		pe, ok := node.(*syntax.ParamExp)
+		if !ok {
+			return nil
+		}
+		desc := explainParamExp(pe, src)
+		if desc == "" {
+			return nil
+		}
+		return []string{fmt.Sprintf("# %s: %s", singleLine(pe), desc)}
+	})
+}
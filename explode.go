@@ -19,12 +19,53 @@ func ExplodeSh(src string, idt int, hang bool) (string, error) {
 	syntax.Walk(pp, func(node syntax.Node) bool {
 		switch x := node.(type) {
 
+		// A command with prefix assignments gets its own line even if we're not
+		// breaking binary commands, so that its assignments (which we put on
+		// their own lines) don't end up after the operator.
 		case *syntax.BinaryCmd:
-			if Cfg.BinCmd {
+			if Cfg.BinCmd || Cfg.EnvVars && hasAssigns(x.Y) {
 				pos := int(x.Y.Position.Offset())
 				chgsIns = append(chgsIns, change{pos, pos, "\n"})
 			}
 
+		case *syntax.BinaryTest:
+			if Cfg.Test && (x.Op == syntax.AndTest || x.Op == syntax.OrTest) {
+				pos := int(x.Y.Pos().Offset())
+				chgsIns = append(chgsIns, change{pos, pos, "\n"})
+			}
+
+		// The printer already puts each statement of a group on its own line,
+		// but it keeps a group with a single statement (e.g., `(cd a && make)`)
+		// on one line.
+		case *syntax.Subshell:
+			if Cfg.Groups {
+				pos := int(x.Lparen.Offset()) + 1
+				chgsIns = append(chgsIns, change{pos, pos, "\n"})
+			}
+
+		// This also covers function bodies, e.g., `f() { a; }`.
+		case *syntax.Block:
+			if Cfg.Groups {
+				pos := int(x.Lbrace.Offset()) + 1
+				chgsIns = append(chgsIns, change{pos, pos, "\n"})
+			}
+
+		case *syntax.DeclClause:
+			if Cfg.Assigns {
+				chgsIns = append(chgsIns, breakAssigns(x.Args)...)
+			}
+
+		// Associative array elements keep their keys, e.g., `[k]=v`.
+		case *syntax.ArrayExpr:
+			if Cfg.Assigns && len(x.Elems) > 1 {
+				pos := int(x.Lparen.Offset()) + 1
+				chgsIns = append(chgsIns, change{pos, pos, "\n"})
+				for _, elem := range x.Elems {
+					pos = int(elem.End().Offset())
+					chgsIns = append(chgsIns, change{pos, pos, "\n"})
+				}
+			}
+
 		case *syntax.ProcSubst:
 			if Cfg.ProcSubst {
 				pos := int(x.OpPos.Offset())
@@ -32,6 +73,19 @@ func ExplodeSh(src string, idt int, hang bool) (string, error) {
 				chgsIns = append(chgsIns, change{pos + 2, pos + 2, "\n"})
 			}
 
+		// Command substitutions in a default (e.g., `${x:-$(cmd)}`) are part of
+		// what we're explaining.
+		case *syntax.ParamExp:
+			if Cfg.Explain && x.Exp != nil && x.Exp.Word != nil {
+				for _, part := range x.Exp.Word.Parts {
+					if cs, ok := part.(*syntax.CmdSubst); ok {
+						pos := int(cs.Left.Offset())
+						chgsIns = append(chgsIns, change{pos, pos, "\\\n"})
+						chgsIns = append(chgsIns, change{pos + 2, pos + 2, "\n"})
+					}
+				}
+			}
+
 		case *syntax.CmdSubst:
 			if Cfg.CmdSubst {
 				pos := int(x.Left.Offset())
@@ -41,23 +95,43 @@ func ExplodeSh(src string, idt int, hang bool) (string, error) {
 
 		case *syntax.CallExpr:
 
-			if Cfg.Args {
-				for _, arg := range x.Args {
-					for _, part := range arg.Parts {
-
-						// It's hard to know if argument is simply a flag or an
-						// option that takes a value. Easiest thing to do is to
-						// break on hyphen. This won't catch all cases, (e.g.,
-						// positional arguments), but it'll handle 90% of work
-						// of sensibily breaking up arguments.
-						if string(src[part.Pos().Offset()]) == "-" {
-							pos := int(part.Pos().Offset())
-							chgsIns = append(chgsIns, change{pos, pos, "\\\n"})
-						}
-					}
+			// Put each prefix assignment on its own line, ahead of the command
+			// (if there is one).
+			if Cfg.EnvVars && len(x.Assigns) > 0 {
+				for _, as := range x.Assigns[1:] {
+					pos := int(as.Pos().Offset())
+					chgsIns = append(chgsIns, change{pos, pos, "\\\n"})
+				}
+				if len(x.Args) > 0 {
+					pos := int(x.Args[0].Pos().Offset())
+					chgsIns = append(chgsIns, change{pos, pos, "\\\n"})
 				}
 			}
 
+			if Cfg.Curl {
+				chgsIns = append(chgsIns, fmtCurlArgs(x)...)
+			}
+
+			if Cfg.Ffmpeg {
+				chgsIns = append(chgsIns, fmtFfmpegArgs(x)...)
+			}
+
+			if Cfg.Mlr {
+				chgsIns = append(chgsIns, fmtMlrChain(x)...)
+			}
+
+			if Cfg.Parallel {
+				chgsIns = append(chgsIns, fmtParallel(x)...)
+			}
+
+			if Cfg.Test {
+				chgsIns = append(chgsIns, fmtTestArgs(x)...)
+			}
+
+			if Cfg.Args && !laysOutArgs(x) {
+				chgsIns = append(chgsIns, breakArgs(x, argBreaks(x))...)
+			}
+
 		case *syntax.ForClause:
 			if Cfg.Clause {
 				pos := int(x.DoPos.Offset()) + 2
@@ -124,6 +198,12 @@ func ExplodeSh(src string, idt int, hang bool) (string, error) {
 
 		case *syntax.CallExpr:
 
+			if Cfg.Env {
+				for _, as := range x.Assigns {
+					noteVarOverride(as.Name.Value)
+				}
+			}
+
 			if len(x.Args) > 0 {
 
 				if Cfg.Env {
@@ -145,6 +225,54 @@ func ExplodeSh(src string, idt int, hang bool) (string, error) {
 					}
 				}
 
+				if Cfg.Curl {
+					chgsCurl, err := fmtCurl(x, false, srcIns)
+					if err != nil {
+						walkErr = fmt.Errorf("could not determine curl changes: %w", err)
+						return false
+					}
+					for _, chg := range chgsCurl {
+						chgsRpl = append(chgsRpl, chg)
+					}
+				}
+
+				if Cfg.Ffmpeg {
+					chgsFfmpeg, err := fmtFfmpeg(x, false, srcIns)
+					if err != nil {
+						walkErr = fmt.Errorf("could not determine ffmpeg changes: %w", err)
+						return false
+					}
+					for _, chg := range chgsFfmpeg {
+						chgsRpl = append(chgsRpl, chg)
+					}
+				}
+
+				if Cfg.Mlr {
+					chgsMlr, err := fmtMlr(x, false, srcIns)
+					if err != nil {
+						walkErr = fmt.Errorf("could not determine mlr changes: %w", err)
+						return false
+					}
+					for _, chg := range chgsMlr {
+						chgsRpl = append(chgsRpl, chg)
+					}
+				}
+
+				if Cfg.Tmpl {
+					chgsTmpl, err := fmtTmpl(x, false, srcIns)
+					if err != nil {
+						walkErr = fmt.Errorf("could not determine template changes: %w", err)
+						return false
+					}
+					for _, chg := range chgsTmpl {
+						chgsRpl = append(chgsRpl, chg)
+					}
+				}
+
+				if Cfg.LongOpts {
+					chgsRpl = append(chgsRpl, fmtLongOpts(x)...)
+				}
+
 				if Cfg.Sh {
 					chgsSh, err := fmtSh(x, false, srcIns)
 					if err != nil {
@@ -171,6 +299,21 @@ func ExplodeSh(src string, idt int, hang bool) (string, error) {
 		return "", fmt.Errorf("could not format program: %w", err)
 	}
 
+	// The printer puts arithmetic on a single line and doesn't indent
+	// parenthesized test expressions, so we handle those ourselves.
+	if Cfg.Arithm {
+		srcRpl, err = explodeArithm(srcRpl)
+		if err != nil {
+			return "", fmt.Errorf("could not explode arithmetic: %w", err)
+		}
+	}
+	if Cfg.Test {
+		srcRpl, err = indentTests(srcRpl)
+		if err != nil {
+			return "", fmt.Errorf("could not indent tests: %w", err)
+		}
+	}
+
 	// Indent.
 	srcIdt, err := indent(srcRpl, idt, hang)
 	if err != nil {
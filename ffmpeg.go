@@ -0,0 +1,229 @@
+package sol
+
+import (
+	"fmt"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// ffmpeg options that don't take a value. Nearly every other ffmpeg option
+// does, so unlike other tools we list the exceptions.
+var ffmpegBoolOpts = []string{
+	"-an", "-dn", "-sn", "-vn",
+	"-autorotate", "-noautorotate",
+	"-benchmark", "-benchmark_all",
+	"-copy_unknown", "-copyts", "-start_at_zero",
+	"-debug_ts",
+	"-dump", "-hex",
+	"-hide_banner",
+	"-ignore_unknown",
+	"-n", "-y",
+	"-noaccurate_seek", "-accurate_seek",
+	"-nostdin", "-stdin",
+	"-nostats", "-stats",
+	"-re",
+	"-report",
+	"-shortest",
+	"-xerror",
+}
+
+// Global ffmpeg options. These apply to neither an input nor an output, so
+// each one gets its own line.
+var ffmpegGlobalOpts = []string{
+	"-y", "-n",
+	"-benchmark", "-benchmark_all",
+	"-filter_complex", "-lavfi", "-filter_complex_script", "-filter_threads",
+	"-filter_hw_device", "-init_hw_device",
+	"-hide_banner",
+	"-ignore_unknown", "-copy_unknown",
+	"-loglevel", "-v",
+	"-nostdin", "-stdin",
+	"-nostats", "-stats", "-stats_period", "-progress",
+	"-report",
+	"-xerror", "-max_error_rate", "-abort_on",
+}
+
+func isFfmpegOpt(val string, opts []string) bool {
+	for _, opt := range opts {
+		if val == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// Options whose value is a filtergraph, e.g., `-filter_complex`, `-vf`, or
+// `-filter:v:0`.
+func isFfmpegFilterOpt(val string) bool {
+	return val == "-filter_complex" || val == "-lavfi" || val == "-vf" || val == "-af" ||
+		val == "-filter" || strings.HasPrefix(val, "-filter:")
+}
+
+// Returns changes that break an ffmpeg invocation into global options, input
+// groups (options through `-i <input>`), and output groups (options through
+// the output file).
+func fmtFfmpegArgs(x *syntax.CallExpr) []change {
+
+	brks := []int{}
+	if len(x.Args) == 0 || getCmdVal(*x) != "ffmpeg" {
+		return breakArgs(x, brks)
+	}
+
+	grpStart := 0
+	for a := 1; a < len(x.Args); a++ {
+		val, _ := wordVal(x.Args[a])
+
+		// Anything that isn't an option is an output file.
+		if !strings.HasPrefix(val, "-") || val == "-" {
+			if grpStart == 0 {
+				grpStart = a
+			}
+			brks = append(brks, grpStart)
+			grpStart = 0
+			continue
+		}
+
+		if isFfmpegOpt(val, ffmpegGlobalOpts) {
+			brks = append(brks, a)
+		} else {
+			if grpStart == 0 {
+				grpStart = a
+			}
+			if val == "-i" {
+				brks = append(brks, grpStart)
+				grpStart = 0
+			}
+		}
+		if !isFfmpegOpt(val, ffmpegBoolOpts) {
+			a++
+		}
+	}
+
+	return breakArgs(x, brks)
+}
+
+// Returns changes that put each filter chain of a filtergraph on its own line
+// and each filter within a chain on its own line (or, if imploding, back onto
+// a single line).
+func fmtFfmpeg(x *syntax.CallExpr, implode bool, src string) ([]change, error) {
+
+	chgs := []change{}
+	if getCmdVal(*x) != "ffmpeg" {
+		return chgs, nil
+	}
+
+	for a := 1; a < len(x.Args)-1; a++ {
+		opt, _ := wordVal(x.Args[a])
+		if !isFfmpegFilterOpt(opt) {
+			continue
+		}
+		a++
+		arg := x.Args[a]
+		if len(arg.Parts) > 1 {
+			continue
+		}
+
+		// We need the filtergraph to be single-quoted so that it remains a
+		// single argument once it spans multiple lines. A literal with
+		// backslashes would mean something different once quoted, though.
+		fg := ""
+		quoted := false
+		switch p := arg.Parts[0].(type) {
+		case *syntax.SglQuoted:
+			fg = p.Value
+			quoted = true
+		case *syntax.Lit:
+			if strings.Contains(p.Value, "\\") {
+				continue
+			}
+			fg = p.Value
+		default:
+			continue
+		}
+
+		chains := splitFiltergraph(fg)
+		fgMod := ""
+		if implode {
+			if !strings.Contains(fg, "\n") {
+				continue
+			}
+			lines := []string{}
+			for _, chain := range chains {
+				lines = append(lines, strings.Join(chain, ","))
+			}
+			fgMod = strings.Join(lines, ";")
+		} else {
+			if len(chains) == 1 && len(chains[0]) == 1 {
+				continue
+			}
+			lines := []string{}
+			for _, chain := range chains {
+				lines = append(lines, strings.Join(chain, ",\n    "))
+			}
+			var err error
+			fgMod, err = indent(strings.Join(lines, ";\n"), lineIndent(src, arg.Pos())+4, true)
+			if err != nil {
+				return chgs, fmt.Errorf("could not indent filtergraph: %w", err)
+			}
+		}
+
+		pos := int(arg.Pos().Offset())
+		end := int(arg.End().Offset())
+		if quoted {
+			chgs = append(chgs, change{pos + 1, end - 1, fgMod})
+		} else {
+			chgs = append(chgs, change{pos, end, "'" + fgMod + "'"})
+		}
+	}
+
+	return chgs, nil
+}
+
+// Splits a filtergraph into chains (at `;`) and each chain into filters (at
+// `,`), ignoring separators that are escaped, quoted, or part of a link label
+// (e.g., `[0:v]`).
+func splitFiltergraph(fg string) [][]string {
+
+	chains := [][]string{}
+	chain := []string{}
+	cur := ""
+	label := false
+	quote := false
+
+	for i := 0; i < len(fg); i++ {
+		c := fg[i]
+		switch {
+		case c == '\\' && i+1 < len(fg):
+			cur += fg[i : i+2]
+			i++
+		case c == '\'':
+			quote = !quote
+			cur += string(c)
+		case quote:
+			cur += string(c)
+		case c == '[':
+			label = true
+			cur += string(c)
+		case c == ']':
+			label = false
+			cur += string(c)
+		case label:
+			cur += string(c)
+		case c == ',':
+			chain = append(chain, strings.TrimSpace(cur))
+			cur = ""
+		case c == ';':
+			chain = append(chain, strings.TrimSpace(cur))
+			chains = append(chains, chain)
+			chain = []string{}
+			cur = ""
+		default:
+			cur += string(c)
+		}
+	}
+	chain = append(chain, strings.TrimSpace(cur))
+	chains = append(chains, chain)
+
+	return chains
+}
@@ -0,0 +1,225 @@
+package sol
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type fishBackend struct{}
+
+// Returns fish's system-wide and user config directories.
+func fishCfgDirs() []string {
+	dirs := []string{"/etc/fish"}
+	if cfgDir := os.Getenv("XDG_CONFIG_HOME"); cfgDir != "" {
+		return append(dirs, filepath.Join(cfgDir, "fish"))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".config", "fish"))
+	}
+	return dirs
+}
+
+func (fishBackend) RcFiles() []string {
+	files := []string{}
+	for _, dir := range fishCfgDirs() {
+		files = append(files, filepath.Join(dir, "config.fish"), filepath.Join(dir, "fish_variables"))
+
+		// Adding or removing a file changes its directory's mtime.
+		for _, sub := range []string{"conf.d", "functions"} {
+			files = append(files, filepath.Join(dir, sub))
+			matches, _ := filepath.Glob(filepath.Join(dir, sub, "*.fish"))
+			files = append(files, matches...)
+		}
+	}
+	return files
+}
+
+// Splits a line that fish printed into words, unquoting them. Inside single
+// quotes, fish only escapes `\'` and `\\`; inside double quotes, it also
+// escapes `\"` and `\$`.
+func fishWords(line string) []string {
+	words := []string{}
+	var word strings.Builder
+	inWord := false
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0 && c == '\\' && i+1 < len(line) && (line[i+1] == quote || line[i+1] == '\\' || quote == '"' && line[i+1] == '$'):
+			i++
+			word.WriteByte(line[i])
+		case quote != 0:
+			word.WriteByte(c)
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == '\\' && i+1 < len(line):
+			i++
+			switch line[i] {
+			case 'n':
+				word.WriteByte('\n')
+			case 't':
+				word.WriteByte('\t')
+			default:
+				word.WriteByte(line[i])
+			}
+			inWord = true
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// Returns the definition of each alias, e.g., `ls -l` for `alias ll 'ls -l'`.
+func getFishAliasDefs(aliasDefs string) map[string]string {
+	ads := map[string]string{}
+	for _, line := range envList(aliasDefs) {
+		words := fishWords(line)
+		if len(words) < 3 || words[0] != "alias" {
+			continue
+		}
+		ads[words[1]] = strings.Join(words[2:], " ")
+	}
+	return ads
+}
+
+// Returns the expansion of each abbreviation, e.g., `git checkout` for `abbr
+// -a -- gco 'git checkout'`.
+func getFishAbbrDefs(abbrDefs string) map[string]string {
+	abds := map[string]string{}
+	for _, line := range envList(abbrDefs) {
+		words := fishWords(line)
+		if len(words) < 3 || words[0] != "abbr" {
+			continue
+		}
+
+		// Older versions of fish don't put `--` before the name.
+		w := 1
+		for ; w < len(words); w++ {
+			if words[w] == "--" {
+				w++
+				break
+			}
+			if !strings.HasPrefix(words[w], "-") {
+				break
+			}
+			if words[w] == "--position" || words[w] == "--regex" || words[w] == "--function" {
+				w++
+			}
+		}
+		if w+1 < len(words) {
+			abds[words[w]] = strings.Join(words[w+1:], " ")
+		}
+	}
+	return abds
+}
+
+// Returns the body of each function, without the comment that says where it
+// was defined and the `function` and `end` lines around it.
+func getFishFuncDefs(funcDefs string) map[string]string {
+	fds := map[string]string{}
+	name := ""
+	body := []string{}
+	flush := func() {
+		if name == "" {
+			return
+		}
+		if len(body) > 0 && strings.HasPrefix(body[0], "function ") {
+			body = body[1:]
+		}
+		if len(body) > 0 && strings.TrimSpace(body[len(body)-1]) == "end" {
+			body = body[:len(body)-1]
+		}
+		for b := range body {
+			body[b] = strings.TrimPrefix(body[b], "    ")
+		}
+		fds[name] = strings.TrimSpace(strings.Join(body, "\n"))
+		name, body = "", []string{}
+	}
+	for _, line := range envList(funcDefs) {
+		if after, ok := strings.CutPrefix(line, envFuncMarker); ok {
+			flush()
+			name = after
+			continue
+		}
+		if len(body) == 0 && (strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "") {
+			continue
+		}
+		body = append(body, line)
+	}
+	flush()
+	return fds
+}
+
+// Returns the value of each variable. fish prints a list's elements as
+// separate words, which we join with spaces.
+func getFishVarDefs(varDefs string) map[string]string {
+	vds := map[string]string{}
+	for _, line := range envList(varDefs) {
+		words := fishWords(line)
+		if len(words) == 0 {
+			continue
+		}
+		vds[words[0]] = strings.Join(words[1:], " ")
+	}
+	return vds
+}
+
+func (fishBackend) Env() (*ShellEnv, error) {
+	env := &ShellEnv{}
+
+	// fish sources its own config (there's no telling which files it'll
+	// pick), and functions and abbreviations might come from universal
+	// variables, so we don't try to source them ourselves.
+	dirs := fishCfgDirs()
+	secs, err := captureEnv(envShellCmd{
+		Shell:   "fish",
+		Args:    []string{"-i", "-c"},
+		Startup: dirs[len(dirs)-1],
+		Fish:    true,
+	}, []envSection{
+		{"abbr-defs", "abbr --show"},
+		{"alias-defs", "alias"},
+		{"builtins", "builtin -n"},
+		{"func-defs", `for f in (functions -n | string split ', '); printf '%s\n' "` + envFuncMarker + `$f"; functions -- $f; end`},
+		{"vars", "set -n"},
+		{"var-defs", "set"},
+		{"path", "printf '%s\\n' $PATH"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	env.AbbrDefs = getFishAbbrDefs(secs["abbr-defs"])
+	env.Abbrs = envNames(env.AbbrDefs)
+	env.AliasDefs = getFishAliasDefs(secs["alias-defs"])
+	env.Aliases = envNames(env.AliasDefs)
+	env.Builtins = envList(secs["builtins"])
+	env.FuncDefs = getFishFuncDefs(secs["func-defs"])
+	env.Funcs = envNames(env.FuncDefs)
+
+	// fish's keywords (e.g., `if` and `begin`) are builtins.
+	env.Keywords = []string{}
+	env.Vars = envList(secs["vars"])
+	env.VarDefs = getFishVarDefs(secs["var-defs"])
+
+	// PATH is a list in fish.
+	env.Paths = envList(secs["path"])
+	env.VarDefs["PATH"] = strings.Join(env.Paths, ":")
+
+	return env, nil
+}
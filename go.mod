@@ -7,6 +7,7 @@ toolchain go1.22.5
 require (
 	github.com/noperator/jqfmt v0.0.0-20240815185611-8fc6f864c295
 	github.com/sirupsen/logrus v1.9.3
+	gopkg.in/yaml.v3 v3.0.1
 	mvdan.cc/sh/v3 v3.5.1
 // noperator.dev/jqfmt v0.0.0-00010101000000-000000000000
 )
@@ -20,6 +20,11 @@ func ImplodeSh(src string) (string, error) {
 	syntax.Walk(pp, func(node syntax.Node) bool {
 		switch x := node.(type) {
 		case *syntax.CallExpr:
+			if Cfg.Env {
+				for _, as := range x.Assigns {
+					noteVarOverride(as.Name.Value)
+				}
+			}
 			if x.Args != nil {
 
 				if Cfg.Env {
@@ -30,7 +35,7 @@ func ImplodeSh(src string) (string, error) {
 					}
 				}
 
-				// For jq/sh command strings, we don't care in this case if the
+				// For curl/ffmpeg/jq/mlr/sh/template command strings, we don't care in this case if the
 				// corresponding cfg values are set (like we do in the explode
 				// case) since we're indiscriminately imploding the whole
 				// program.
@@ -44,6 +49,48 @@ func ImplodeSh(src string) (string, error) {
 					chgsRpl = append(chgsRpl, chg)
 				}
 
+				chgsCurl, err := fmtCurl(x, true, src)
+				if err != nil {
+					walkErr = fmt.Errorf("could not determine curl changes: %w", err)
+					return false
+				}
+				for _, chg := range chgsCurl {
+					chgsRpl = append(chgsRpl, chg)
+				}
+
+				chgsFfmpeg, err := fmtFfmpeg(x, true, src)
+				if err != nil {
+					walkErr = fmt.Errorf("could not determine ffmpeg changes: %w", err)
+					return false
+				}
+				for _, chg := range chgsFfmpeg {
+					chgsRpl = append(chgsRpl, chg)
+				}
+
+				chgsMlr, err := fmtMlr(x, true, src)
+				if err != nil {
+					walkErr = fmt.Errorf("could not determine mlr changes: %w", err)
+					return false
+				}
+				for _, chg := range chgsMlr {
+					chgsRpl = append(chgsRpl, chg)
+				}
+
+				chgsTmpl, err := fmtTmpl(x, true, src)
+				if err != nil {
+					walkErr = fmt.Errorf("could not determine template changes: %w", err)
+					return false
+				}
+				for _, chg := range chgsTmpl {
+					chgsRpl = append(chgsRpl, chg)
+				}
+
+				// Unlike the rest, this is opt-in since it changes the
+				// command rather than just its layout.
+				if Cfg.ShortOpts {
+					chgsRpl = append(chgsRpl, fmtShortOpts(x)...)
+				}
+
 				chgsSh, err := fmtSh(x, true, src)
 				if err != nil {
 					walkErr = fmt.Errorf("could not determine shell changes: %w", err)
@@ -61,11 +108,5 @@ func ImplodeSh(src string) (string, error) {
 	}
 
 	// Apply _replace_ changes.
-	srcRpl := modProg(src, chgsRpl)
-	pp, err = parseProg(srcRpl)
-	if err != nil {
-		return "", fmt.Errorf("could not parse program: %w", err)
-	}
-
-	return treeToStr(pp, syntax.SingleLine(true)), nil
+	return oneLineProg(modProg(src, chgsRpl))
 }
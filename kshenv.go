@@ -0,0 +1,117 @@
+package sol
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ksh93 or mksh, which mostly print their environments the same way.
+type kshBackend struct {
+	Shell string
+}
+
+// The reserved words of ksh93 and mksh, which neither one can list.
+var kshKeywords = []string{"!", "[[", "]]", "case", "do", "done", "elif", "else", "esac", "fi", "for", "function", "if", "in", "select", "then", "time", "until", "while", "{", "}"}
+
+// The builtins of mksh, which can't list them (unlike ksh93's `builtin`).
+var mkshBuiltins = []string{".", ":", "[", "alias", "bg", "bind", "break", "builtin", "cat", "cd", "chdir", "command", "continue", "echo", "eval", "exec", "exit", "export", "false", "fc", "fg", "getopts", "global", "hash", "jobs", "kill", "let", "mknod", "print", "printf", "pwd", "read", "readonly", "realpath", "rename", "return", "set", "shift", "sleep", "source", "suspend", "test", "times", "trap", "true", "typeset", "ulimit", "umask", "unalias", "unset", "wait", "whence"}
+
+// Returns the file that an interactive ksh sources: $ENV, or else
+// `~/.kshrc` (or `~/.mkshrc` for mksh).
+func (b kshBackend) envFile() string {
+	if env := os.Getenv("ENV"); env != "" {
+		return os.ExpandEnv(env)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, "."+b.Shell+"rc")
+}
+
+func (b kshBackend) RcFiles() []string {
+	files := []string{"/etc/profile", "/etc/" + b.Shell + "rc"}
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, ".profile"))
+	}
+	if env := b.envFile(); env != "" {
+		files = append(files, env)
+	}
+	return files
+}
+
+func (b kshBackend) Env() (*ShellEnv, error) {
+	env := &ShellEnv{}
+
+	// Point $ENV somewhere empty, so that we can source it ourselves and
+	// tell it apart from the shell's own noise.
+	rcFiles := []string{}
+	if envFile := b.envFile(); envFile != "" {
+		rcFiles = append(rcFiles, envFile)
+	}
+	secs, err := captureEnv(envShellCmd{
+		Shell:   b.Shell,
+		Args:    []string{"-i", "-c"},
+		Environ: []string{"ENV=/dev/null"},
+		RcFiles: rcFiles,
+	}, []envSection{
+		{"alias-defs", "alias"},
+		{"builtins", "builtin 2>/dev/null || :"},
+		{"func-defs", "typeset -f"},
+		{"var-defs", "set"},
+		{"path", `printf '%s\n' "$PATH"`},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Each line is like `ll='ls -l'`, without `alias` in front.
+	aliasDefs := []string{}
+	for _, line := range envList(secs["alias-defs"]) {
+		aliasDefs = append(aliasDefs, "alias "+line)
+	}
+	env.AliasDefs, err = getAliasDefs(strings.Join(aliasDefs, "\n"))
+	if err != nil {
+		return nil, err
+	}
+	env.Aliases = envNames(env.AliasDefs)
+
+	// ksh93 lists builtins that are bound to a path (e.g., `/opt/ast/bin/cat`)
+	// along with the rest.
+	env.Builtins = []string{}
+	for _, builtin := range envList(secs["builtins"]) {
+		if !strings.Contains(builtin, "/") {
+			env.Builtins = append(env.Builtins, builtin)
+		}
+	}
+	if len(env.Builtins) == 0 {
+		env.Builtins = mkshBuiltins
+	}
+	env.Keywords = kshKeywords
+
+	env.FuncDefs, err = getFuncDefs(secs["func-defs"])
+	if err != nil {
+		log.Warningf("could not get %s func defs: %v", b.Shell, err)
+		env.FuncDefs = map[string]string{}
+	}
+	env.Funcs = envNames(env.FuncDefs)
+
+	// ksh93 has compound variables (e.g., `.sh.version`) that we can't parse.
+	env.VarDefs, err = getVarDefs(secs["var-defs"])
+	if err != nil {
+		log.Warningf("could not get %s var defs: %v", b.Shell, err)
+		env.VarDefs = map[string]string{}
+	}
+	delete(env.VarDefs, "ENV")
+	if envVar, ok := os.LookupEnv("ENV"); ok {
+		env.VarDefs["ENV"] = envVar
+	}
+	env.Vars = envNames(env.VarDefs)
+	env.VarDefs["PATH"] = secs["path"]
+	env.Paths = strings.Split(secs["path"], ":")
+
+	return env, nil
+}
@@ -0,0 +1,342 @@
+package sol
+
+import (
+	_ "embed"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+//go:embed opt-specs/long-opts.txt
+var longOptsTxt string
+
+// Long forms of each command's (or subcommand's, e.g., `git commit`) short
+// options, in the same format as `opt-specs/long-opts.txt`.
+var longOpts map[string]map[byte]string
+
+// Adds long forms in the same format as `opt-specs/long-opts.txt`.
+func parseLongOpts(txt string, los map[string]map[byte]string) {
+	for _, line := range strings.Split(txt, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		cmd := fields[0]
+		fields = fields[1:]
+		if len(fields) > 0 && !strings.Contains(fields[0], ":") {
+			cmd = fmt.Sprintf("%s %s", cmd, fields[0])
+			fields = fields[1:]
+		}
+		if _, ok := los[cmd]; !ok {
+			los[cmd] = map[byte]string{}
+		}
+		for _, f := range fields {
+			opt, long, ok := strings.Cut(f, ":")
+			if !ok || len(opt) != 1 || long == "" {
+				log.Warningf("invalid long option for %s: %s", cmd, f)
+				continue
+			}
+			los[cmd][opt[0]] = long
+		}
+	}
+}
+
+// Returns the long forms of short options for all known commands, loading
+// them the first time. The user's long forms (e.g.,
+// `~/.config/sol/long-opts.txt` on Linux) extend the embedded ones.
+func getLongOpts() map[string]map[byte]string {
+	if longOpts != nil {
+		return longOpts
+	}
+	los := map[string]map[byte]string{}
+	parseLongOpts(longOptsTxt, los)
+	parseLongOpts(readUserCfgFile(userCfgFile("long-opts.txt")), los)
+	longOpts = los
+	return longOpts
+}
+
+// A short option (or a cluster of them) and its long forms, e.g., `-xzf` and
+// `--extract --gzip --file`. Each long form is kept with any value that was
+// attached to the short option (e.g., `-n5` is `--lines 5`). `Sub` is the
+// subcommand whose options they are (e.g., `commit` for `git commit -am`), if
+// any.
+type longForm struct {
+	Arg   int
+	Sub   string
+	Short string
+	Longs []string
+}
+
+// Returns the long forms of a short option or cluster, or false if any
+// option in it doesn't have a known long form.
+func expandShortOpts(opt string, long func(byte) (string, bool), spec *optSpec) ([]string, bool) {
+	longs := []string{}
+	for o := 1; o < len(opt); o++ {
+		l, ok := long(opt[o])
+		if !ok {
+			return nil, false
+		}
+		rest := opt[o+1:]
+
+		// An optional value can only be attached, e.g., `-i.bak`.
+		if name, ok := strings.CutSuffix(l, "?"); ok {
+			if rest != "" {
+				return append(longs, fmt.Sprintf("--%s=%s", name, rest)), true
+			}
+			longs = append(longs, "--"+name)
+			continue
+		}
+
+		for _, name := range strings.Split(l, "+") {
+			longs = append(longs, "--"+name)
+		}
+		if spec != nil && spec.Short[opt[o]] > 0 && rest != "" {
+			longs[len(longs)-1] += " " + rest
+			return longs, true
+		}
+	}
+	return longs, true
+}
+
+// Returns the long forms of all the short options of a command that we know
+// about. Options that we don't know about are left out.
+func longForms(x *syntax.CallExpr) []longForm {
+	forms := []longForm{}
+	if len(x.Args) == 0 {
+		return forms
+	}
+	cmd := filepath.Base(getCmdVal(*x))
+	los := getLongOpts()
+	if _, ok := los[cmd]; !ok {
+		return forms
+	}
+	specs := getOptSpecs()
+	spec := specs[cmd]
+
+	sub := ""
+	subSpec := ""
+	long := func(opt byte) (string, bool) {
+		if l, ok := los[cmd+" "+sub][opt]; ok {
+			return l, true
+		}
+		l, ok := los[cmd][opt]
+		return l, ok
+	}
+
+	for a := 1; a < len(x.Args); a++ {
+		if len(x.Args[a].Parts) != 1 {
+			continue
+		}
+		lit, ok := x.Args[a].Parts[0].(*syntax.Lit)
+		if !ok {
+			continue
+		}
+		val := lit.Value
+		if val == "--" {
+			break
+		}
+
+		// The first positional argument that's a known subcommand (e.g.,
+		// `commit` in `git -C dir commit -am msg`) has its own options.
+		if !strings.HasPrefix(val, "-") || val == "-" {
+			if _, ok := los[cmd+" "+val]; ok && sub == "" {
+				sub = val
+			}
+			if _, ok := specs[cmd+" "+val]; ok && subSpec == "" {
+				subSpec = val
+				spec = subOptSpec(specs, cmd, subSpec)
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(val, "--") && (spec == nil || spec.Long[val] == 0) {
+			if longs, ok := expandShortOpts(val, long, spec); ok {
+				forms = append(forms, longForm{a, sub, val, longs})
+			}
+		}
+
+		// Skip over the option's value, which might look like an option.
+		a += min(optNumVals(spec, val, x.Args, a), len(x.Args)-1-a)
+	}
+	return forms
+}
+
+// Returns changes that replace short options with their long forms, each on
+// its own line if we're breaking arguments.
+func fmtLongOpts(x *syntax.CallExpr) []change {
+	chgs := []change{}
+	sep := " "
+	if Cfg.Args {
+		sep = " \\\n"
+	}
+	for _, f := range longForms(x) {
+		arg := x.Args[f.Arg]
+		chgs = append(chgs, change{int(arg.Pos().Offset()), int(arg.End().Offset()), strings.Join(f.Longs, sep)})
+	}
+	return chgs
+}
+
+// Puts a comment above each statement that spells out the long forms of its
+// short options, e.g., `# tar -xzf: --extract --gzip --file`.
+func annotateLongOpts(src string) (string, error) {
+	return commentStmts(src, func(node syntax.Node) []string {
+		x, ok := node.(*syntax.CallExpr)
+		if !ok {
+			return nil
+		}
+		cmts := []string{}
+		for _, f := range longForms(x) {
+			cmd := getCmdVal(*x)
+			if f.Sub != "" {
+				cmd += " " + f.Sub
+			}
+			cmts = append(cmts, fmt.Sprintf("# %s %s: %s", cmd, f.Short, strings.Join(f.Longs, " ")))
+		}
+		return cmts
+	})
+}
+
+// Returns the short form of a long option (e.g., `x` for tar's
+// `--extract`). To stay conservative, a long option doesn't have a short form
+// if it has more than one (e.g., cp's `-r` and `-R`), or if it's only part of
+// one (e.g., rsync's `-P`) or only takes an attached value (e.g., sed's `-i`).
+func shortOpt(los map[string]map[byte]string, cmd string, sub string, long string) (byte, bool) {
+	for _, key := range []string{cmd + " " + sub, cmd} {
+		opts := []byte{}
+		for opt, l := range los[key] {
+			if l == long {
+				opts = append(opts, opt)
+			}
+		}
+		if len(opts) == 1 {
+			return opts[0], true
+		}
+		if len(opts) > 1 {
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// Returns changes that rewrite the long options of a command into short
+// ones, clustering adjacent boolean flags (e.g., `--recursive --verbose` into
+// `-rv`). An option that takes a value can only end a cluster (e.g., `-xzf
+// x.tgz`). We only touch commands whose options we fully know about, and
+// anything we don't recognize is left alone.
+func fmtShortOpts(x *syntax.CallExpr) []change {
+	chgs := []change{}
+	if len(x.Args) == 0 {
+		return chgs
+	}
+	cmd := filepath.Base(getCmdVal(*x))
+	los := getLongOpts()
+	specs := getOptSpecs()
+	spec := specs[cmd]
+	if _, ok := los[cmd]; !ok || spec == nil {
+		return chgs
+	}
+
+	// The current cluster of short options, which starts at argument
+	// `start` and ends at argument `end`.
+	start, end := 0, 0
+	cluster := ""
+	val := ""
+	longs := 0
+	flush := func() {
+		if start > 0 && (end > start || longs > 0) {
+			str := "-" + cluster
+			if val != "" {
+				str += " " + val
+			}
+			chgs = append(chgs, change{int(x.Args[start].Pos().Offset()), int(x.Args[end].End().Offset()), str})
+		}
+		start, end, cluster, val, longs = 0, 0, "", "", 0
+	}
+
+	sub := ""
+	for a := 1; a < len(x.Args); a++ {
+		lit, ok := x.Args[a].Parts[0].(*syntax.Lit)
+		if !ok || len(x.Args[a].Parts) != 1 {
+			flush()
+			continue
+		}
+		word := lit.Value
+		if word == "--" {
+			break
+		}
+		if !strings.HasPrefix(word, "-") || word == "-" {
+			flush()
+			if _, ok := los[cmd+" "+word]; ok && sub == "" {
+				sub = word
+				spec = subOptSpec(specs, cmd, sub)
+			}
+			continue
+		}
+
+		// Work out the short options (and value, if any) that this argument
+		// adds to the cluster.
+		opts := ""
+		optVal := ""
+		takesVal := false
+		if long, attached, hasVal := strings.Cut(word, "="); strings.HasPrefix(word, "--") {
+			opt, ok := shortOpt(los, cmd, sub, strings.TrimPrefix(long, "--"))
+			if ok && spec.Long[long] == 0 && spec.Short[opt] == 0 && !hasVal {
+				opts = string(opt)
+			} else if ok && spec.Long[long] == 1 && spec.Short[opt] == 1 {
+				opts = string(opt)
+				takesVal = true
+				if hasVal {
+					optVal = attached
+				}
+			}
+		} else if spec.Long[word] == 0 {
+			opts = word[1:]
+			for o := 0; o < len(opts); o++ {
+				l, ok := los[cmd+" "+sub][opts[o]]
+				if !ok {
+					l, ok = los[cmd][opts[o]]
+				}
+				if !ok || strings.ContainsAny(l, "?+") || spec.Short[opts[o]] > 1 {
+					opts = ""
+					break
+				}
+				if spec.Short[opts[o]] == 1 {
+					if o != len(opts)-1 {
+						opts = ""
+					}
+					takesVal = true
+					break
+				}
+			}
+		}
+
+		// Anything else ends the current cluster, and we skip over its value.
+		if opts == "" || optVal != "" && (strings.HasPrefix(optVal, "-") || strings.ContainsAny(optVal, " \t")) {
+			flush()
+			a += min(optNumVals(spec, word, x.Args, a), len(x.Args)-1-a)
+			continue
+		}
+
+		if start == 0 {
+			start = a
+		}
+		end = a
+		cluster += opts
+		if strings.HasPrefix(word, "--") {
+			longs++
+		}
+		if takesVal {
+			val = optVal
+			flush()
+			if optVal == "" {
+				a++
+			}
+		}
+	}
+	flush()
+
+	return chgs
+}
@@ -0,0 +1,289 @@
+package sol
+
+import (
+	"fmt"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Miller main options that take a separate value (e.g., `--ifs ;`). Options
+// not listed here are assumed to be boolean flags.
+var mlrValOpts = []string{
+	"-i", "-o", "--io",
+	"-s",
+	"--fail-color",
+	"--files",
+	"--flatsep", "--fflatsep", "--jflatsep", "--oflatsep",
+	"--from",
+	"--fs", "--ifs", "--ofs", "--ifs-regex",
+	"--gen-field-name", "--gen-start", "--gen-step", "--gen-stop",
+	"--help-color",
+	"--key-color",
+	"--load", "--mload",
+	"--nr-progress-mod",
+	"--ofmt",
+	"--pass-color",
+	"--prepipe", "--prepipex",
+	"--ps", "--ips", "--ops", "--ips-regex",
+	"--records-per-batch",
+	"--rs", "--irs", "--ors",
+	"--seed",
+	"--tz",
+	"--value-color",
+}
+
+// Returns the indices of the first argument of each verb in a Miller verb
+// chain (i.e., the verb's name).
+func mlrVerbs(x *syntax.CallExpr) []int {
+	verbs := []int{}
+	a := 1
+	for ; a < len(x.Args); a++ {
+		val, _ := wordVal(x.Args[a])
+		if !strings.HasPrefix(val, "-") {
+			break
+		}
+
+		// `--mfrom` takes any number of file names, up to `--`.
+		if val == "--mfrom" {
+			for a++; a < len(x.Args); a++ {
+				if val, _ := wordVal(x.Args[a]); val == "--" {
+					break
+				}
+			}
+			continue
+		}
+		for _, opt := range mlrValOpts {
+			if val == opt {
+				a++
+				break
+			}
+		}
+	}
+	if a >= len(x.Args) {
+		return verbs
+	}
+	verbs = append(verbs, a)
+	for ; a < len(x.Args)-1; a++ {
+		if val, _ := wordVal(x.Args[a]); val == "then" {
+			verbs = append(verbs, a+1)
+		}
+	}
+	return verbs
+}
+
+// Returns the indices of the DSL expression arguments of a `put` or `filter`
+// verb, e.g., `put -q -e '$y = 1' -e '$z = 2'` or `filter '$x > 3'`.
+func mlrExprs(x *syntax.CallExpr, verb int) []int {
+	exprs := []int{}
+	if val, _ := wordVal(x.Args[verb]); val != "put" && val != "filter" {
+		return exprs
+	}
+	for a := verb + 1; a < len(x.Args); a++ {
+		val, _ := wordVal(x.Args[a])
+		switch {
+		case val == "then":
+			return exprs
+		case val == "-e" && a+1 < len(x.Args):
+			a++
+			exprs = append(exprs, a)
+		case val == "-f" || val == "-s":
+			a++
+		case strings.HasPrefix(val, "-"):
+		default:
+			if len(exprs) == 0 {
+				exprs = append(exprs, a)
+			}
+			return exprs
+		}
+	}
+	return exprs
+}
+
+// Returns changes that break a Miller verb chain before each `then`.
+func fmtMlrChain(x *syntax.CallExpr) []change {
+	brks := []int{}
+	if len(x.Args) == 0 || getCmdVal(*x) != "mlr" {
+		return breakArgs(x, brks)
+	}
+	for _, verb := range mlrVerbs(x)[1:] {
+		brks = append(brks, verb-1)
+	}
+	return breakArgs(x, brks)
+}
+
+// Returns changes that put each statement of a Miller DSL expression on its
+// own line (or, if imploding, back onto a single line).
+func fmtMlr(x *syntax.CallExpr, implode bool, src string) ([]change, error) {
+
+	chgs := []change{}
+	if getCmdVal(*x) != "mlr" {
+		return chgs, nil
+	}
+
+	verbs := mlrVerbs(x)
+	for _, verb := range verbs {
+		for _, expr := range mlrExprs(x, verb) {
+
+			// Double-quoted expressions may contain shell expansions, so we
+			// leave them alone.
+			part, ok := x.Args[expr].Parts[0].(*syntax.SglQuoted)
+			if !ok || len(x.Args[expr].Parts) > 1 {
+				continue
+			}
+
+			lines, hasComment := splitMlrDSL(part.Value)
+			exprMod := ""
+			if implode {
+
+				// A comment runs to the end of its line, so we can't safely
+				// put an expression that has one onto a single line.
+				if hasComment {
+					continue
+				}
+				for l := range lines {
+					lines[l] = strings.TrimSpace(lines[l])
+				}
+				exprMod = strings.Join(lines, " ")
+			} else {
+				var err error
+				exprMod, err = indent(strings.Join(lines, "\n"), lineIndent(src, part.Pos())+4, true)
+				if err != nil {
+					return chgs, fmt.Errorf("could not indent expression: %w", err)
+				}
+			}
+			pos := int(part.Pos().Offset())
+			end := int(part.End().Offset())
+			chgs = append(chgs, change{pos + 1, end - 1, exprMod})
+		}
+	}
+
+	return chgs, nil
+}
+
+// Splits a Miller DSL expression into lines: one per statement, with the
+// bodies of curly-braced blocks (e.g., `if`, `for`, `end`) indented. Also
+// reports whether the expression contains a comment.
+func splitMlrDSL(dsl string) ([]string, bool) {
+
+	lines := []string{}
+	cur := ""
+	depth := 0
+	hasComment := false
+
+	// Stack of open brackets; true for a curly-braced block, false for
+	// parentheses, square brackets, and map literals.
+	blocks := []bool{}
+	nested := func() bool {
+		for _, b := range blocks {
+			if !b {
+				return true
+			}
+		}
+		return false
+	}
+
+	emit := func() {
+		ln := strings.TrimSpace(cur)
+		if ln != "" {
+			lines = append(lines, strings.Repeat("    ", depth)+ln)
+		}
+		cur = ""
+	}
+
+	for i := 0; i < len(dsl); i++ {
+		c := dsl[i]
+		switch {
+
+		// Braced field and out-of-stream variable names (e.g., `${total cost}`
+		// or `@{sum x}`) can contain spaces and aren't blocks, so they're
+		// copied verbatim, too.
+		case (c == '$' || c == '@') && i+1 < len(dsl) && dsl[i+1] == '{':
+			j := strings.IndexByte(dsl[i:], '}')
+			if j < 0 {
+				j = len(dsl) - i - 1
+			}
+			cur += dsl[i : i+j+1]
+			i += j
+
+		// String literals are copied verbatim.
+		case c == '"':
+			j := i + 1
+			for ; j < len(dsl) && dsl[j] != '"'; j++ {
+				if dsl[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(dsl) {
+				j = len(dsl) - 1
+			}
+			cur += dsl[i : j+1]
+			i = j
+
+		// Comments run to the end of the line.
+		case c == '#':
+			hasComment = true
+			j := strings.IndexByte(dsl[i:], '\n')
+			if j < 0 {
+				j = len(dsl) - i
+			}
+			cur += dsl[i : i+j]
+			i += j - 1
+			emit()
+
+		// Collapse whitespace (including line breaks) to a single space.
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if !strings.HasSuffix(cur, " ") {
+				cur += " "
+			}
+
+		case c == '(' || c == '[':
+			blocks = append(blocks, false)
+			cur += string(c)
+
+		case c == '{':
+
+			// Map literals follow an assignment, argument, key, or index.
+			prev := strings.TrimSpace(cur)
+			isMap := nested() || strings.HasSuffix(prev, "=") || strings.HasSuffix(prev, ",") || strings.HasSuffix(prev, ":") || strings.HasSuffix(prev, "?")
+			blocks = append(blocks, !isMap)
+			cur += "{"
+			if !isMap {
+				emit()
+				depth++
+			}
+
+		case c == ')' || c == ']' || c == '}':
+			isBlock := false
+			if len(blocks) > 0 {
+				isBlock = blocks[len(blocks)-1]
+				blocks = blocks[:len(blocks)-1]
+			}
+			if !isBlock {
+				cur += string(c)
+				break
+			}
+			emit()
+			if depth > 0 {
+				depth--
+			}
+			cur = "}"
+
+			// Keep `} else {` and `} elif (...) {` together.
+			rest := strings.TrimLeft(dsl[i+1:], " \t\r\n")
+			if !strings.HasPrefix(rest, "else") && !strings.HasPrefix(rest, "elif") && !strings.HasPrefix(rest, ";") {
+				emit()
+			}
+
+		case c == ';' && !nested():
+			cur += ";"
+			emit()
+
+		default:
+			cur += string(c)
+		}
+	}
+	emit()
+
+	return lines, hasComment
+}
@@ -0,0 +1,276 @@
+package sol
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+//go:embed opt-specs/opt-specs.txt
+var optSpecsTxt string
+
+// The options of a command that take a separate value, and how many values
+// each one takes. Short options (e.g., `-f`) can be clustered (e.g., `-xzf`),
+// and other options (e.g., `--file` or find's `-name`) must match exactly.
+type optSpec struct {
+	Short map[byte]int
+	Long  map[string]int
+}
+
+var optSpecs map[string]*optSpec
+var optSpecsFile string
+
+// Returns the path of a file in the user's config directory (e.g.,
+// `~/.config/sol/opt-specs.txt` on Linux).
+func userCfgFile(name string) string {
+	cfgDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(cfgDir, "sol", name)
+}
+
+// Returns the path of the user's option specs, which extend the embedded
+// ones.
+func userOptSpecsFile() string {
+	if Cfg.OptSpecs != "" {
+		return Cfg.OptSpecs
+	}
+	return userCfgFile("opt-specs.txt")
+}
+
+// Returns the contents of a user's config file, or an empty string if there
+// isn't one.
+func readUserCfgFile(path string) string {
+	if path == "" {
+		return ""
+	}
+	txt, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warningf("could not read %s: %v", path, err)
+		}
+		return ""
+	}
+	return string(txt)
+}
+
+// Adds option specs in the same format as `opt-specs/opt-specs.txt`.
+func parseOptSpecs(txt string, specs map[string]*optSpec) {
+	for _, line := range strings.Split(txt, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		cmd := fields[0]
+		fields = fields[1:]
+		if len(fields) > 0 && !strings.HasPrefix(fields[0], "-") {
+			addOptSpecs(specs, cmd, []string{})
+			cmd = fmt.Sprintf("%s %s", cmd, fields[0])
+			fields = fields[1:]
+		}
+		addOptSpecs(specs, cmd, fields)
+	}
+}
+
+// Returns the option spec for a subcommand (e.g., `docker run`), which adds
+// to and takes priority over the command's own.
+func subOptSpec(specs map[string]*optSpec, cmd string, sub string) *optSpec {
+	subSpec := &optSpec{Short: map[byte]int{}, Long: map[string]int{}}
+	for _, spec := range []*optSpec{specs[cmd], specs[cmd+" "+sub]} {
+		if spec == nil {
+			continue
+		}
+		for opt, num := range spec.Short {
+			subSpec.Short[opt] = num
+		}
+		for opt, num := range spec.Long {
+			subSpec.Long[opt] = num
+		}
+	}
+	return subSpec
+}
+
+func addOptSpecs(specs map[string]*optSpec, cmd string, opts []string) {
+	if _, ok := specs[cmd]; !ok {
+		specs[cmd] = &optSpec{Short: map[byte]int{}, Long: map[string]int{}}
+	}
+	for _, opt := range opts {
+		num := 1
+		if name, numStr, ok := strings.Cut(opt, ":"); ok {
+			n, err := strconv.Atoi(numStr)
+			if err != nil || n < 1 {
+				log.Warningf("invalid option spec for %s: %s", cmd, opt)
+				continue
+			}
+			opt, num = name, n
+		}
+		if len(opt) == 2 && opt[0] == '-' && opt[1] != '-' {
+			specs[cmd].Short[opt[1]] = num
+		} else {
+			specs[cmd].Long[opt] = num
+		}
+	}
+}
+
+// Returns option specs for all known commands, loading them the first time
+// (or when the user's spec file changes).
+func getOptSpecs() map[string]*optSpec {
+	usrFile := userOptSpecsFile()
+	if optSpecs != nil && optSpecsFile == usrFile {
+		return optSpecs
+	}
+
+	specs := map[string]*optSpec{}
+	parseOptSpecs(optSpecsTxt, specs)
+
+	// Commands with their own formatters already know their options.
+	shortOpts := func(opts string) []string {
+		strs := []string{}
+		for _, o := range opts {
+			strs = append(strs, "-"+string(o))
+		}
+		return strs
+	}
+	addOptSpecs(specs, "curl", append(shortOpts(curlValOpts), curlLongValOpts...))
+	addOptSpecs(specs, "parallel", parallelValOpts)
+	addOptSpecs(specs, "xargs", append(shortOpts(xargsValOpts), xargsLongValOpts...))
+
+	parseOptSpecs(readUserCfgFile(usrFile), specs)
+
+	optSpecs = specs
+	optSpecsFile = usrFile
+	return optSpecs
+}
+
+// Returns how many of the following arguments an option takes as its value.
+// Without a spec for the command, we guess that an option takes a value if
+// it's followed by something that isn't an option, as long as that isn't the
+// last argument (which is usually positional, e.g., `cmd -v file`).
+func optNumVals(spec *optSpec, opt string, args []*syntax.Word, a int) int {
+	if spec == nil {
+		if strings.Contains(opt, "=") || a+2 >= len(args) {
+			return 0
+		}
+		if !strings.HasPrefix(opt, "--") && len(opt) > 2 {
+			return 0
+		}
+		if next, ok := wordVal(args[a+1]); ok && strings.HasPrefix(next, "-") {
+			return 0
+		}
+		return 1
+	}
+
+	if num, ok := spec.Long[opt]; ok {
+		return num
+	}
+	if strings.HasPrefix(opt, "--") {
+		return 0
+	}
+
+	// In a cluster of short options (e.g., `-xzf`), only the last one can
+	// take a separate value. Any other option that takes a value has it
+	// attached (e.g., `-n5`).
+	for o := 1; o < len(opt); o++ {
+		if num, ok := spec.Short[opt[o]]; ok {
+			if o == len(opt)-1 {
+				return num
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// Reports whether an enabled formatter already lays out a command's arguments
+// its own way (e.g., Miller's verb chains), which breaking them by option
+// spec would undo.
+func laysOutArgs(x *syntax.CallExpr) bool {
+	if len(x.Args) == 0 {
+		return false
+	}
+	switch getCmdVal(*x) {
+	case "curl":
+		return Cfg.Curl
+	case "ffmpeg":
+		return Cfg.Ffmpeg
+	case "mlr":
+		return Cfg.Mlr
+	case "parallel":
+		return Cfg.Parallel
+	case "xargs":
+		return Cfg.Sh
+	}
+	return Cfg.Test && isTestCmd(x)
+}
+
+// Returns the arguments of a command that each start a unit that belongs on
+// its own line: an option along with its value(s), or a positional argument.
+// Commands without any options are left alone.
+func argBreaks(x *syntax.CallExpr) []int {
+	brks := []int{}
+	if len(x.Args) == 0 {
+		return brks
+	}
+	specs := getOptSpecs()
+	cmd := filepath.Base(getCmdVal(*x))
+	spec := specs[cmd]
+
+	endOpts := false
+	hasOpts := false
+	sub := ""
+	for a := 1; a < len(x.Args); a++ {
+		brks = append(brks, a)
+		if endOpts {
+			continue
+		}
+
+		// A quoted word is never an option, even if it starts with `-`.
+		if _, ok := x.Args[a].Parts[0].(*syntax.Lit); !ok {
+			continue
+		}
+		val, ok := wordVal(x.Args[a])
+		if !ok || !strings.HasPrefix(val, "-") || val == "-" {
+			if _, ok := specs[cmd+" "+val]; ok && sub == "" {
+				sub = val
+				spec = subOptSpec(specs, cmd, sub)
+
+				// Keep a subcommand with its command, e.g., `docker run`.
+				if a == 1 {
+					brks = brks[:len(brks)-1]
+				}
+			}
+			continue
+		}
+		hasOpts = true
+		if val == "--" {
+			endOpts = true
+			continue
+		}
+
+		// find's actions run a command up to a `;` or `+`.
+		if val == "-exec" || val == "-execdir" || val == "-ok" || val == "-okdir" {
+			for a+1 < len(x.Args) {
+				a++
+				if end, _ := wordVal(x.Args[a]); end == ";" || end == "\\;" || end == "+" {
+					break
+				}
+			}
+			continue
+		}
+
+		a += min(optNumVals(spec, val, x.Args, a), len(x.Args)-1-a)
+	}
+
+	// Something like `cat file` or `cp src dst` reads fine as is.
+	if !hasOpts || len(brks) < 2 {
+		return []int{}
+	}
+	return brks
+}
@@ -0,0 +1,218 @@
+package sol
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// GNU parallel options that take a separate value (e.g., `-j 4`). Options
+// not listed here are assumed to be boolean flags.
+var parallelValOpts = []string{
+	"-a", "--arg-file",
+	"-C", "--colsep",
+	"-d", "--delimiter",
+	"-E", "--eof",
+	"-I", "--replace",
+	"-j", "-P", "--jobs", "--max-procs",
+	"-L", "--max-lines",
+	"-n", "--max-args",
+	"-N",
+	"-S", "--sshlogin",
+	"-s", "--max-chars",
+	"--basefile", "--bf",
+	"--block", "--block-size",
+	"--delay",
+	"--env",
+	"--halt", "--halt-on-error",
+	"--header",
+	"--joblog",
+	"--load",
+	"--memfree",
+	"--res", "--results",
+	"--retries",
+	"--return",
+	"--rpl",
+	"--sshloginfile", "--slf",
+	"--tagstring", "--tag-string",
+	"--termseq",
+	"--timeout",
+	"--tmpdir",
+	"--transferfile", "--tf",
+	"--workdir", "--wd",
+}
+
+// Input source separators. Each one starts a new group of arguments.
+var parallelSeps = []string{":::", ":::+", "::::", "::::+"}
+
+// Matches GNU parallel replacement strings, e.g., `{}`, `{.}`, `{/}`, `{//}`,
+// `{/.}`, `{#}`, `{%}`, `{1}`, `{2.}`, and perl expressions like `{= s/a/b/ =}`.
+var parallelRplRe = regexp.MustCompile(`\{(-?[0-9]+)?(\.|/|//|/\.)?\}|\{[#%]\}|\{=(.*?)=\}`)
+
+// The arguments of a parallel invocation, broken up into units that belong on
+// the same line. Each value is an index into the command's arguments.
+type parallelArgs struct {
+	Opts    []int  // start of each option (and its value, if any)
+	Tmpl    int    // start of the command template; 0 if none
+	TmplEnd int    // end (exclusive) of the command template
+	Srcs    []int  // start of each input source group
+	Rpl     string // custom replacement string set with `-I`
+}
+
+func isParallelSep(val string) bool {
+	for _, sep := range parallelSeps {
+		if val == sep {
+			return true
+		}
+	}
+	return false
+}
+
+func parseParallel(x *syntax.CallExpr) parallelArgs {
+	pa := parallelArgs{}
+	a := 1
+	for ; a < len(x.Args); a++ {
+		val, _ := wordVal(x.Args[a])
+		if !strings.HasPrefix(val, "-") || isParallelSep(val) {
+			break
+		}
+		pa.Opts = append(pa.Opts, a)
+
+		// Attached values (e.g., `-j4` or `--jobs=4`) don't consume the next
+		// argument.
+		if strings.Contains(val, "=") {
+			continue
+		}
+		for _, opt := range parallelValOpts {
+			if val == opt && a+1 < len(x.Args) {
+				if opt == "-I" || opt == "--replace" {
+					pa.Rpl, _ = wordVal(x.Args[a+1])
+				}
+				a++
+				break
+			}
+		}
+	}
+
+	// Everything up to the first input source separator is the command
+	// template.
+	if a < len(x.Args) {
+		if val, _ := wordVal(x.Args[a]); !isParallelSep(val) {
+			pa.Tmpl = a
+		}
+	}
+	for ; a < len(x.Args); a++ {
+		val, _ := wordVal(x.Args[a])
+		if isParallelSep(val) {
+			if pa.TmplEnd == 0 {
+				pa.TmplEnd = a
+			}
+			pa.Srcs = append(pa.Srcs, a)
+		}
+	}
+	if pa.TmplEnd == 0 {
+		pa.TmplEnd = len(x.Args)
+	}
+	if pa.Tmpl == 0 {
+		pa.TmplEnd = 0
+	}
+
+	return pa
+}
+
+// Returns the replacement strings used by a parallel command template, in
+// order of appearance.
+func parallelRpls(tmpl string, rpl string) []string {
+	rpls := []string{}
+	seen := map[string]bool{}
+	matches := parallelRplRe.FindAllString(tmpl, -1)
+	if rpl != "" && strings.Contains(tmpl, rpl) {
+		matches = append([]string{rpl}, matches...)
+	}
+	for _, m := range matches {
+		if !seen[m] {
+			seen[m] = true
+			rpls = append(rpls, m)
+		}
+	}
+	return rpls
+}
+
+// Returns changes that break a parallel invocation into options (kept with
+// their values), the command template (kept intact), and one line per input
+// source group.
+func fmtParallel(x *syntax.CallExpr) []change {
+
+	chgs := []change{}
+	if len(x.Args) == 0 || getCmdVal(*x) != "parallel" {
+		return chgs
+	}
+
+	pa := parseParallel(x)
+
+	brks := append([]int{}, pa.Opts...)
+	if pa.Tmpl > 0 {
+		brks = append(brks, pa.Tmpl)
+	}
+	brks = append(brks, pa.Srcs...)
+
+	return breakArgs(x, brks)
+}
+
+// Returns a description of a replacement string, e.g., `basename of input 1
+// without extension` for `{1/.}`.
+func explainParallelRpl(rpl string, customRpl string) string {
+	if rpl == customRpl {
+		return "input"
+	}
+	m := parallelRplRe.FindStringSubmatch(rpl)
+	if m == nil {
+		return ""
+	}
+	switch {
+	case rpl == "{#}":
+		return "job sequence number"
+	case rpl == "{%}":
+		return "job slot number"
+	case strings.HasPrefix(rpl, "{="):
+		return fmt.Sprintf("input modified by the perl expression %s", strings.TrimSpace(m[3]))
+	}
+	in := "input"
+	if m[1] != "" {
+		in = fmt.Sprintf("input %s", m[1])
+	}
+	switch m[2] {
+	case ".":
+		return fmt.Sprintf("%s without extension", in)
+	case "/":
+		return fmt.Sprintf("basename of %s", in)
+	case "//":
+		return fmt.Sprintf("dirname of %s", in)
+	case "/.":
+		return fmt.Sprintf("basename of %s without extension", in)
+	}
+	return in
+}
+
+// Puts a comment above each parallel command that describes the replacement
+// strings in its command template, one per line.
+func explainParallel(src string) (string, error) {
+	return commentStmts(src, func(node syntax.Node) []string {
+		x, ok := node.(*syntax.CallExpr)
+		if !ok || len(x.Args) == 0 || getCmdVal(*x) != "parallel" {
+			return nil
+		}
+		pa := parseParallel(x)
+		if pa.Tmpl == 0 {
+			return nil
+		}
+		tmpl := src[x.Args[pa.Tmpl].Pos().Offset():x.Args[pa.TmplEnd-1].End().Offset()]
+		cmts := []string{}
+		for _, rpl := range parallelRpls(tmpl, pa.Rpl) {
+			cmts = append(cmts, fmt.Sprintf("# %s: %s", rpl, explainParallelRpl(rpl, pa.Rpl)))
+		}
+		return cmts
+	})
+}
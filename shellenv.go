@@ -12,6 +12,10 @@ printing the following:
 00000030  48 20 20 20 20 1b 48 20  20 20 20 1b 48 20 20 20  |H    .H    .H   |
 00000040  20 1b 48 20 20 20 20 1b  48 20 20 20 20 1b 48 20  | .H    .H    .H |
 
+To keep that kind of noise out of the environment, each section is now written
+to its own file descriptor between markers (see `captureEnv`), and anything
+else that the dotfiles print is reported instead.
+
 */
 
 package sol
@@ -20,8 +24,9 @@ import (
 	_ "embed"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -30,20 +35,44 @@ import (
 
 //go:embed std-cmds/cmds.txt
 var stdCmds []byte
+
+// What each command in the last program that we formatted with `Cfg.Env`
+// resolves to: `abbr`, `alias`, `keyword`, `function`, `builtin`, `file`, or
+// `missing` if it's nowhere to be found.
 var Cmds map[string]string
 var nonstdCmds []string
 var nonstdCmdDefs map[string]string
+var cmdDeps map[string][]string
+var overriddenVars []string
+var overriddenVarDefs map[string]string
+
+// A shell environment: the names that a command might refer to (aliases,
+// functions, builtins, etc.) and what they're defined as, along with the
+// variables and the directories in $PATH.
+type ShellEnv struct {
+	Abbrs     []string          `json:"abbrs"`
+	AbbrDefs  map[string]string `json:"abbrDefs"`
+	Aliases   []string          `json:"aliases"`
+	AliasDefs map[string]string `json:"aliasDefs"`
+	Keywords  []string          `json:"keywords"`
+	Funcs     []string          `json:"funcs"`
+	FuncDefs  map[string]string `json:"funcDefs"`
+	Builtins  []string          `json:"builtins"`
+	Vars      []string          `json:"vars"`
+	VarDefs   map[string]string `json:"varDefs"`
+	Paths     []string          `json:"paths"`
+}
 
-type shellEnv struct {
-	Aliases   []string
-	AliasDefs map[string]string
-	Keywords  []string
-	Funcs     []string
-	FuncDefs  map[string]string
-	Builtins  []string
-	Vars      []string
-	VarDefs   map[string]string
-	Paths     []string
+// Returns a copy of a shell environment with the values of its variables left
+// out, except for PATH, which is all that we need to resolve commands. The
+// rest might well be secrets (e.g., tokens).
+func (env *ShellEnv) WithoutVarValues() *ShellEnv {
+	stripped := *env
+	stripped.VarDefs = map[string]string{}
+	if path, ok := env.VarDefs["PATH"]; ok {
+		stripped.VarDefs["PATH"] = path
+	}
+	return &stripped
 }
 
 type cmdType struct {
@@ -51,7 +80,9 @@ type cmdType struct {
 	Def  string
 }
 
-func getCmdTypes(cmd string) ([]*cmdType, error) {
+// Returns everything that a command might refer to in a shell environment,
+// in the order that the shell looks for it.
+func resolveCmd(env *ShellEnv, cmd string) []*cmdType {
 	/*
 		-t	output a single word which is one of `alias', `keyword',
 		`function', `builtin', `file' or `', if NAME is an alias,
@@ -64,6 +95,12 @@ func getCmdTypes(cmd string) ([]*cmdType, error) {
 
 	cmdTypes := []*cmdType{}
 
+	// fish expands abbreviations as they're typed, before anything else.
+	if _, ok := env.AbbrDefs[cmd]; ok {
+		cmdTypes = append(cmdTypes, &cmdType{Type: "abbr", Def: env.AbbrDefs[cmd]})
+		log.Debugln(cmd, "is abbr")
+	}
+
 	if _, ok := env.AliasDefs[cmd]; ok {
 		cmdTypes = append(cmdTypes, &cmdType{Type: "alias", Def: env.AliasDefs[cmd]})
 		log.Debugln(cmd, "is alias")
@@ -98,9 +135,23 @@ func getCmdTypes(cmd string) ([]*cmdType, error) {
 		}
 	}
 
-	if len(cmdTypes) == 0 {
-		return nil, fmt.Errorf("command %s not found", cmd)
+	return cmdTypes
+}
+
+func getCmdTypes(cmd string) ([]*cmdType, error) {
+
+	// Remove leading path from command.
+	cmd = filepath.Base(cmd)
+
+	cmdTypes := resolveCmd(env, cmd)
+
+	// Each command only needs to be looked into once, which also keeps
+	// definitions that (indirectly) run themselves from sending us around in
+	// circles.
+	if _, ok := cmdDeps[cmd]; ok {
+		return cmdTypes, nil
 	}
+	cmdDeps[cmd] = []string{}
 
 	stdLocs := []string{
 		"/bin",
@@ -118,14 +169,45 @@ func getCmdTypes(cmd string) ([]*cmdType, error) {
 	if !found {
 		nonstdCmds = append(nonstdCmds, cmd)
 	}
+
+	// Someone else's one-liner might well use tools that we don't have,
+	// which is worth pointing out, but not worth giving up over.
+	if len(cmdTypes) == 0 {
+		log.Debugln(cmd, "is missing")
+		Cmds[cmd] = "missing"
+		nonstdCmdDefs[cmd] = fmt.Sprintf("# missing: %s", cmd)
+		return cmdTypes, nil
+	}
+	Cmds[cmd] = cmdTypes[0].Type
 	for _, ct := range cmdTypes[0:1] {
 
-		if ct.Type == "alias" || ct.Type == "function" {
-			line, err := ImplodeSh(ct.Def)
+		if ct.Type == "abbr" || ct.Type == "alias" || ct.Type == "function" {
+
+			// The definition is only put on one line for show, as is. Unlike
+			// imploding, that doesn't resolve the commands in it (which we
+			// do ourselves below) or rewrite any of its options.
+			line, err := oneLineProg(ct.Def)
+
+			// Definitions in another shell's syntax (e.g., fish) just get
+			// put on one line.
 			if err != nil {
-				log.Warningf("could not implode shell: %v", err)
+				log.Debugf("could not put definition on one line: %v", err)
+				line = oneLineDef(ct.Def)
 			}
 			nonstdCmdDefs[cmd] = fmt.Sprintf("# %s is %s: %s", cmd, ct.Type, line)
+
+			// A function or alias is only as portable as the commands that
+			// it runs (e.g., helper functions of its own).
+			deps, err := programCmds(ct.Def)
+			if err != nil {
+				log.Debugf("could not get commands that %s runs: %v", cmd, err)
+			}
+			for _, dep := range deps {
+				cmdDeps[cmd] = append(cmdDeps[cmd], dep)
+				if _, err := getCmdTypes(dep); err != nil {
+					return nil, err
+				}
+			}
 		} else if ct.Type == "file" {
 			stdCmd := false
 			for _, sc := range strings.Split(string(stdCmds), "\n") {
@@ -153,14 +235,66 @@ func getCmdTypes(cmd string) ([]*cmdType, error) {
 
 }
 
-func getVarDefs() (map[string]string, error) {
+// Returns the non-standard commands in the order that their definitions
+// should be listed: each one after the ones that it depends on.
+func nonstdCmdOrder() []string {
+	order := []string{}
+	visited := map[string]bool{}
+	var visit func(cmd string)
+	visit = func(cmd string) {
+		if visited[cmd] {
+			return
+		}
+		visited[cmd] = true
+		for _, dep := range cmdDeps[cmd] {
+			visit(dep)
+		}
+		if _, ok := nonstdCmdDefs[cmd]; ok {
+			order = append(order, cmd)
+		}
+	}
+
+	// The program's own commands are listed last to first.
+	for c := len(nonstdCmds) - 1; c >= 0; c-- {
+		visit(nonstdCmds[c])
+	}
+	return order
+}
 
-	varDefs, err := exec.Command("bash", "-c", "set -o posix; set").Output()
-	if err != nil {
-		return nil, fmt.Errorf("could not get var defs: %w", err)
+// Puts a definition on one line without parsing it, by joining its lines
+// with `;`.
+func oneLineDef(def string) string {
+	lines := []string{}
+	for _, l := range strings.Split(def, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return strings.Join(lines, "; ")
+}
+
+// Notes when a prefix assignment (e.g., `AWS_PROFILE=prod aws s3 ls`)
+// overrides a variable that the current environment already provides. Only
+// the variable's name is noted, since its value might be a secret.
+func noteVarOverride(name string) {
+	if !slices.Contains(env.Vars, name) {
+		return
+	}
+	if _, ok := overriddenVarDefs[name]; ok {
+		return
 	}
+	log.Debugln(name, "is set in environment")
+	overriddenVars = append(overriddenVars, name)
+	overriddenVarDefs[name] = fmt.Sprintf("# %s is set in environment", name)
+}
+
+func getVarDefs(varDefs string) (map[string]string, error) {
+
 	parser := syntax.NewParser()
-	varDefsParsed, err := parser.Parse(strings.NewReader(string(varDefs)), "")
+	varDefsParsed, err := parser.Parse(strings.NewReader(varDefs), "")
+	if err != nil {
+		return nil, fmt.Errorf("could not parse var defs: %w", err)
+	}
 
 	vds := map[string]string{}
 
@@ -186,14 +320,10 @@ func getVarDefs() (map[string]string, error) {
 
 }
 
-func getFuncDefs() (map[string]string, error) {
+func getFuncDefs(funcDefs string) (map[string]string, error) {
 
-	funcDefs, err := exec.Command("bash", "-ic", "declare -f").Output()
-	if err != nil {
-		return nil, fmt.Errorf("could not get func defs: %w", err)
-	}
 	parser := syntax.NewParser()
-	funcDefsParsed, err := parser.Parse(strings.NewReader(string(funcDefs)), "")
+	funcDefsParsed, err := parser.Parse(strings.NewReader(funcDefs), "")
 	if err != nil {
 		return nil, fmt.Errorf("could not parse func defs: %w", err)
 	}
@@ -223,14 +353,10 @@ func getFuncDefs() (map[string]string, error) {
 	return fds, nil
 }
 
-func getAliasDefs() (map[string]string, error) {
+func getAliasDefs(aliasDefs string) (map[string]string, error) {
 
-	aliasDefs, err := exec.Command("bash", "-ic", "alias").Output()
-	if err != nil {
-		return nil, fmt.Errorf("could not get alias defs: %w", err)
-	}
 	parser := syntax.NewParser()
-	aliasDefsParsed, err := parser.Parse(strings.NewReader(string(aliasDefs)), "")
+	aliasDefsParsed, err := parser.Parse(strings.NewReader(aliasDefs), "")
 	if err != nil {
 		return nil, fmt.Errorf("could not parse alias defs: %w", err)
 	}
@@ -248,11 +374,16 @@ func getAliasDefs() (map[string]string, error) {
 
 					printer := syntax.NewPrinter()
 
-					aliasName := strings.TrimSuffix(cmd.Args[1].Parts[0].(*syntax.Lit).Value, "=")
+					// zsh puts `--` before names that start with `-`.
+					arg := cmd.Args[1]
+					if val, _ := wordVal(arg); val == "--" && len(cmd.Args) > 2 {
+						arg = cmd.Args[2]
+					}
 
-					finalStr := ""
+					// zsh only quotes values that need it, e.g., `alias g=git`.
+					aliasName, finalStr, _ := strings.Cut(arg.Parts[0].(*syntax.Lit).Value, "=")
 
-					for _, p := range cmd.Args[1].Parts[1:] {
+					for _, p := range arg.Parts[1:] {
 						var ts strings.Builder
 						printer.Print(&ts, p)
 						tsStr := ts.String()
@@ -281,69 +412,65 @@ func getAliasDefs() (map[string]string, error) {
 	return ads, nil
 }
 
-func getShellEnv() (*shellEnv, error) {
-	env := &shellEnv{}
-
-	// Aliases
-	aliases, err := exec.Command("bash", "-ic", "compgen -a").Output()
-	if err != nil {
-		return nil, fmt.Errorf("could not get aliases: %w", err)
-	}
-	env.Aliases = strings.Split(string(aliases), "\n")
-
-	// AliasDefs
-	aliasDefs, err := getAliasDefs()
-	if err != nil {
-		return nil, fmt.Errorf("could not get alias defs: %w", err)
-	}
-	env.AliasDefs = aliasDefs
+// A shell whose environment we know how to capture.
+type envBackend interface {
+	// Captures the shell's aliases, functions, variables, etc.
+	Env() (*ShellEnv, error)
 
-	// Builtins
-	builtins, err := exec.Command("bash", "-ic", "compgen -b").Output()
-	if err != nil {
-		return nil, fmt.Errorf("could not get builtins: %w", err)
-	}
-	env.Builtins = strings.Split(string(builtins), "\n")
+	// Returns all the startup files that the shell might source, login or
+	// not, which the captured environment depends on.
+	RcFiles() []string
+}
 
-	// Funcs
-	funcs, err := exec.Command("bash", "-ic", "compgen -A function").Output()
-	if err != nil {
-		return nil, fmt.Errorf("could not get funcs: %w", err)
-	}
-	env.Funcs = strings.Split(string(funcs), "\n")
+// The shells whose environments we know how to capture, by name.
+var envBackends = map[string]envBackend{
+	"bash": bashBackend{},
+	"fish": fishBackend{},
+	"ksh":  kshBackend{"ksh"},
+	"mksh": kshBackend{"mksh"},
+	"zsh":  zshBackend{},
+}
 
-	// FuncDefs
-	funcDefs, err := getFuncDefs()
-	if err != nil {
-		return nil, fmt.Errorf("could not get func defs: %w", err)
+// Returns the shell whose environment we inspect: the one in `Cfg.Shell`,
+// or else the user's login shell if we know how to inspect it, or else bash.
+func envShell() (string, error) {
+	if Cfg.Shell != "" {
+		if _, ok := envBackends[Cfg.Shell]; !ok {
+			shells := []string{}
+			for shell := range envBackends {
+				shells = append(shells, shell)
+			}
+			sort.Strings(shells)
+			return "", fmt.Errorf("unsupported shell %s (supported: %s)", Cfg.Shell, strings.Join(shells, ", "))
+		}
+		return Cfg.Shell, nil
 	}
-	env.FuncDefs = funcDefs
-
-	// Keywords
-	keywords, err := exec.Command("bash", "-ic", "compgen -k").Output()
-	if err != nil {
-		return nil, fmt.Errorf("could not get keywords: %w", err)
+	if shell := filepath.Base(os.Getenv("SHELL")); envBackends[shell] != nil {
+		return shell, nil
 	}
-	env.Keywords = strings.Split(string(keywords), "\n")
+	return "bash", nil
+}
 
-	// Vars
-	vars, err := exec.Command("bash", "-ic", "compgen -v").Output()
-	if err != nil {
-		return nil, fmt.Errorf("could not get vars: %w", err)
-	}
-	env.Vars = strings.Split(string(vars), "\n")
+// Marks the start of each function in a `func-defs` section, for shells
+// that can't print them in a way that we can split up. Shells drop comments
+// when they print a function, so this can't show up in one.
+const envFuncMarker = "#sol-func "
 
-	// VarDefs
-	varDefs, err := getVarDefs()
-	if err != nil {
-		return nil, fmt.Errorf("could not get var defs: %w", err)
+// Splits a section of the shell environment that lists one name per line.
+func envList(sec string) []string {
+	if sec == "" {
+		return []string{}
 	}
-	env.VarDefs = varDefs
+	return strings.Split(sec, "\n")
+}
 
-	// Extract a few important items.
-	if path, ok := env.VarDefs["PATH"]; ok {
-		env.Paths = strings.Split(path, ":")
+// Returns the names of the definitions in a section of the shell
+// environment, for shells that can't list them on their own.
+func envNames(defs map[string]string) []string {
+	names := []string{}
+	for name := range defs {
+		names = append(names, name)
 	}
-
-	return env, nil
+	sort.Strings(names)
+	return names
 }
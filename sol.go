@@ -2,30 +2,55 @@ package sol
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/noperator/jqfmt"
 )
 
 type SolCfg struct {
 	Args      bool
+	Arithm    bool
+	Assigns   bool
 	BinCmd    bool
 	Clause    bool
 	CmdSubst  bool
+	Curl      bool
 	Env       bool
+	EnvVars   bool
+	Explain   bool
+	Ffmpeg    bool
+	Groups    bool
+	LongCmts  bool
+	LongOpts  bool
+	Mlr       bool
+	Parallel  bool
 	ProcSubst bool
 	Redir     bool
-
-	MaxWidth int
-	OneLine  bool
-	Sh       bool
-	Jq       bool
-	JqFmtCfg jqfmt.JqFmtCfg
+	ShortOpts bool
+	Test      bool
+
+	EnvTimeout time.Duration
+	MaxWidth   int
+	NoEnvCache bool
+	OneLine    bool
+	OptSpecs   string
+	RefreshEnv bool
+	Sh         bool
+	Shell      string
+	Jq         bool
+	Tmpl       bool
+	JqFmtCfg   jqfmt.JqFmtCfg
 	// JqFuncs []string
+
+	// Provides the shell environment for `Env`. If it's nil, we capture the
+	// user's own.
+	EnvProvider EnvProvider
 }
 
 var Cfg SolCfg
 
-var env *shellEnv
+var env *ShellEnv
 
 func Format(src string) (string, error) {
 
@@ -36,8 +61,11 @@ func Format(src string) (string, error) {
 
 		nonstdCmds = []string{}
 		nonstdCmdDefs = map[string]string{}
+		cmdDeps = map[string][]string{}
+		overriddenVars = []string{}
+		overriddenVarDefs = map[string]string{}
 
-		env, err = getShellEnv()
+		env, err = envProvider().ShellEnv()
 		if err != nil {
 			return "", fmt.Errorf("could not get shell environment: %v", err)
 		}
@@ -62,10 +90,33 @@ func Format(src string) (string, error) {
 		}
 	}
 
-	// Prettify modified program.
-	srcModFmt, err := fmtProg(srcMod)
-	if err != nil {
-		return "", fmt.Errorf("could not format program: %v", err)
+	// The exploded program is already printed (and has the arithmetic and test
+	// expressions that the printer flattens broken back up), and in one-line
+	// mode, the imploded program is already as pretty as it's going to get.
+	srcModFmt := srcMod
+
+	// Describe parameter expansions.
+	if Cfg.Explain && !Cfg.OneLine {
+		srcModFmt, err = explainExps(srcModFmt)
+		if err != nil {
+			return "", fmt.Errorf("could not explain expansions: %w", err)
+		}
+	}
+
+	// Describe parallel replacement strings.
+	if Cfg.Parallel && !Cfg.OneLine {
+		srcModFmt, err = explainParallel(srcModFmt)
+		if err != nil {
+			return "", fmt.Errorf("could not explain parallel replacement strings: %w", err)
+		}
+	}
+
+	// Spell out short options.
+	if Cfg.LongCmts && !Cfg.OneLine {
+		srcModFmt, err = annotateLongOpts(srcModFmt)
+		if err != nil {
+			return "", fmt.Errorf("could not annotate long options: %w", err)
+		}
 	}
 
 	// Normalize indents.
@@ -76,10 +127,19 @@ func Format(src string) (string, error) {
 
 	// Prepend non-standard command definitions to formatted program.
 	if Cfg.Env {
-		for _, cmd := range nonstdCmds {
-			if _, ok := nonstdCmdDefs[cmd]; ok {
-				srcModFmtNml = nonstdCmdDefs[cmd] + "\n" + srcModFmtNml
-			}
+		defs := []string{}
+		for _, cmd := range nonstdCmdOrder() {
+			defs = append(defs, nonstdCmdDefs[cmd])
+		}
+		if len(defs) > 0 {
+			srcModFmtNml = strings.Join(defs, "\n") + "\n" + srcModFmtNml
+		}
+		notes := []string{}
+		for _, name := range overriddenVars {
+			notes = append(notes, overriddenVarDefs[name])
+		}
+		if len(notes) > 0 {
+			srcModFmtNml = strings.Join(notes, "\n") + "\n" + srcModFmtNml
 		}
 	}
 
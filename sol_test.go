@@ -2,11 +2,14 @@ package sol
 
 import (
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/noperator/jqfmt"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 )
 
 func TestExplode(t *testing.T) {
@@ -16,6 +19,8 @@ func TestExplode(t *testing.T) {
 		outFile string
 	}{
 		{"testdata/args-in.sh", "testdata/args-out.sh"},
+		{"testdata/args-specs-in.sh", "testdata/args-specs-out.sh"},
+		{"testdata/arithm-ops-in.sh", "testdata/arithm-ops-out.sh"},
 		{"testdata/bincmd-and-in.sh", "testdata/bincmd-and-out.sh"},
 		{"testdata/bincmd-or-in.sh", "testdata/bincmd-or-out.sh"},
 		{"testdata/bincmd-pipe-in.sh", "testdata/bincmd-pipe-out.sh"},
@@ -26,11 +31,28 @@ func TestExplode(t *testing.T) {
 		{"testdata/clause-while-in.sh", "testdata/clause-while-out.sh"},
 		{"testdata/cmdsubst-backtick-in.sh", "testdata/cmdsubst-backtick-out.sh"}, // `` is deprecated, switches to $()
 		{"testdata/cmdsubst-paren-in.sh", "testdata/cmdsubst-paren-out.sh"},
+		{"testdata/curl-dblquoted-in.sh", "testdata/curl-dblquoted-out.sh"},
+		{"testdata/curl-json-in.sh", "testdata/curl-json-out.sh"},
+		{"testdata/ffmpeg-filtergraph-in.sh", "testdata/ffmpeg-filtergraph-out.sh"},
+		{"testdata/groups-subshell-in.sh", "testdata/groups-subshell-out.sh"},
+		{"testdata/stmts-list-in.sh", "testdata/stmts-list-out.sh"}, // the printer always splits statement lists
+		{"testdata/assigns-array-in.sh", "testdata/assigns-array-out.sh"},
+		{"testdata/envvars-prefix-in.sh", "testdata/envvars-prefix-out.sh"},
+		{"testdata/explain-paramexp-in.sh", "testdata/explain-paramexp-out.sh"},
 		{"testdata/jq_jqarr-in.sh", "testdata/jq_jqarr-out.sh"},
 		{"testdata/jq_jqobj-in.sh", "testdata/jq_jqobj-out.sh"},
 		{"testdata/jq_jqop-add-in.sh", "testdata/jq_jqop-add-out.sh"},
 		{"testdata/jq_jqop-comma-in.sh", "testdata/jq_jqop-comma-out.sh"},
 		{"testdata/jq_jqop-pipe-in.sh", "testdata/jq_jqop-pipe-out.sh"},
+		{"testdata/longcmts-tar-in.sh", "testdata/longcmts-tar-out.sh"},
+		{"testdata/longopts-kubectl-in.sh", "testdata/longopts-kubectl-out.sh"},
+		{"testdata/longopts-tar-in.sh", "testdata/longopts-tar-out.sh"},
+		{"testdata/longopts-xargs-in.sh", "testdata/longopts-xargs-out.sh"},
+		{"testdata/mlr-braced-in.sh", "testdata/mlr-braced-out.sh"},
+		{"testdata/mlr-io-in.sh", "testdata/mlr-io-out.sh"},
+		{"testdata/mlr-verbs-in.sh", "testdata/mlr-verbs-out.sh"},
+		{"testdata/parallel-inputs-in.sh", "testdata/parallel-inputs-out.sh"},
+		{"testdata/parallel-rpls-in.sh", "testdata/parallel-rpls-out.sh"},
 		{"testdata/procsubst-input-in.sh", "testdata/procsubst-input-out.sh"},
 		{"testdata/procsubst-output-in.sh", "testdata/procsubst-output-out.sh"},
 		{"testdata/redir-herestring-in.sh", "testdata/redir-herestring-out.sh"},
@@ -38,7 +60,15 @@ func TestExplode(t *testing.T) {
 		{"testdata/redir-stdin-in.sh", "testdata/redir-stdin-out.sh"},
 		{"testdata/redir-stdout-in.sh", "testdata/redir-stdout-out.sh"},
 		{"testdata/sh_args-parallel-in.sh", "testdata/sh_args-parallel-out.sh"},
+		{"testdata/shortopts-tar-in.sh", "testdata/shortopts-tar-out.sh"},
+		{"testdata/sh_bincmd-dblquoted-in.sh", "testdata/sh_bincmd-dblquoted-out.sh"},
 		{"testdata/sh_bincmd-xargs-in.sh", "testdata/sh_bincmd-xargs-out.sh"},
+		{"testdata/sh_bincmd-xargsopts-in.sh", "testdata/sh_bincmd-xargsopts-out.sh"},
+		{"testdata/test-logical-in.sh", "testdata/test-logical-out.sh"},
+		{"testdata/test-operands-in.sh", "testdata/test-operands-out.sh"},
+		{"testdata/tmpl-gotemplate-in.sh", "testdata/tmpl-gotemplate-out.sh"},
+		{"testdata/tmpl-jsonpath-in.sh", "testdata/tmpl-jsonpath-out.sh"},
+		{"testdata/tmpl-newlines-in.sh", "testdata/tmpl-newlines-out.sh"},
 	}
 
 	for _, c := range cases {
@@ -87,18 +117,60 @@ func TestExplode(t *testing.T) {
 			if cfgType == "cmdsubst" {
 				Cfg.CmdSubst = true
 			}
+			if cfgType == "curl" {
+				Cfg.Curl = true
+			}
+			if cfgType == "ffmpeg" {
+				Cfg.Ffmpeg = true
+			}
+			if cfgType == "groups" {
+				Cfg.Groups = true
+			}
+			if cfgType == "assigns" {
+				Cfg.Assigns = true
+			}
+			if cfgType == "envvars" {
+				Cfg.EnvVars = true
+			}
+			if cfgType == "explain" {
+				Cfg.Explain = true
+			}
+			if cfgType == "longcmts" {
+				Cfg.LongCmts = true
+			}
+			if cfgType == "longopts" {
+				Cfg.LongOpts = true
+			}
+			if cfgType == "shortopts" {
+				Cfg.ShortOpts = true
+			}
+			if cfgType == "mlr" {
+				Cfg.Mlr = true
+			}
+			if cfgType == "parallel" {
+				Cfg.Parallel = true
+			}
 			if cfgType == "procsubst" {
 				Cfg.ProcSubst = true
 			}
 			if cfgType == "args" {
 				Cfg.Args = true
 			}
+			if cfgType == "arithm" {
+				Cfg.Arithm = true
+			}
 			if cfgType == "sh" {
 				Cfg.Sh = true
 			}
 			if cfgType == "jq" {
 				Cfg.Jq = true
 			}
+			if cfgType == "test" {
+				Cfg.Test = true
+			}
+			if cfgType == "tmpl" {
+				Cfg.Tmpl = true
+			}
 			if cfgType == "jqobj" {
 				Cfg.JqFmtCfg.Obj = true
 			}
@@ -155,6 +227,18 @@ func TestComplex(t *testing.T) {
 			},
 			"testdata/complex-2-out.sh",
 		},
+		{
+			"testdata/complex-3-in.sh",
+			SolCfg{
+				Args:     true,
+				Ffmpeg:   true,
+				Mlr:      true,
+				Parallel: true,
+				Sh:       true,
+				Test:     true,
+			},
+			"testdata/complex-3-out.sh",
+		},
 	}
 
 	for _, c := range cases {
@@ -185,3 +269,360 @@ func TestComplex(t *testing.T) {
 		}
 	}
 }
+
+func TestEnv(t *testing.T) {
+
+	yamlEnv, err := LoadStaticEnvProvider("testdata/env.yaml")
+	if err != nil {
+		t.Fatalf("could not load env: %s", err)
+	}
+	jsonEnv, err := LoadStaticEnvProvider("testdata/env.json")
+	if err != nil {
+		t.Fatalf("could not load env: %s", err)
+	}
+	depsEnv, err := LoadStaticEnvProvider("testdata/env-deps.yaml")
+	if err != nil {
+		t.Fatalf("could not load env: %s", err)
+	}
+	structEnv := NewStaticEnvProvider(ShellEnv{
+		AbbrDefs:  map[string]string{"gco": "git checkout"},
+		AliasDefs: map[string]string{"ll": "ls -l"},
+		FuncDefs:  map[string]string{"mkcd": `mkdir -p "$1" && cd "$1"`},
+		Builtins:  []string{"cd", "echo"},
+		VarDefs:   map[string]string{"AWS_PROFILE": "dev", "PATH": "testdata/env-bin"},
+	})
+
+	cases := []struct {
+		inFile    string
+		env       EnvProvider
+		shortOpts bool
+		outFile   string
+	}{
+		{"testdata/env-in.sh", yamlEnv, false, "testdata/env-out.sh"},
+		{"testdata/env-in.sh", jsonEnv, false, "testdata/env-out.sh"},
+		{"testdata/env-in.sh", structEnv, false, "testdata/env-out.sh"},
+		{"testdata/env-missing-in.sh", yamlEnv, false, "testdata/env-missing-out.sh"},
+		{"testdata/env-deps-in.sh", depsEnv, false, "testdata/env-deps-out.sh"},
+		{"testdata/env-short-in.sh", depsEnv, true, "testdata/env-short-out.sh"},
+	}
+
+	for _, c := range cases {
+
+		inBytes, err := os.ReadFile(c.inFile)
+		if err != nil {
+			t.Fatalf("failed to open input file: %s", err)
+		}
+		in := string(inBytes)
+
+		wantBytes, err := os.ReadFile(c.outFile)
+		if err != nil {
+			t.Fatalf("failed to open want file: %s", err)
+		}
+		want := string(wantBytes)
+
+		Cfg = SolCfg{
+			BinCmd:      true,
+			Env:         true,
+			EnvVars:     true,
+			EnvProvider: c.env,
+			ShortOpts:   c.shortOpts,
+		}
+
+		out, err := Format(in)
+		if err != nil {
+			t.Fatalf("could not format program: %v", err)
+		}
+
+		if !reflect.DeepEqual(want, out) {
+			t.Logf("want: %s", want)
+			t.Logf("have: %s", out)
+			t.Errorf("%s does not match %s", c.inFile, c.outFile)
+		}
+	}
+}
+
+// A shell backend that "captures" a fixed environment, and counts how many
+// times it does.
+type testEnvBackend struct {
+	rcFiles  []string
+	captures int
+}
+
+func (b *testEnvBackend) Env() (*ShellEnv, error) {
+	b.captures++
+	return &ShellEnv{
+		Vars:    []string{"PATH", "TOKEN"},
+		VarDefs: map[string]string{"PATH": os.Getenv("PATH"), "TOKEN": "hunter2"},
+	}, nil
+}
+
+func (b *testEnvBackend) RcFiles() []string {
+	return b.rcFiles
+}
+
+func TestEnvCache(t *testing.T) {
+
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+
+	// The rc file sources a file that sources another, which sources the rc
+	// file again.
+	rc := filepath.Join(dir, ".testrc")
+	aliases := filepath.Join(dir, ".test_aliases")
+	more := filepath.Join(dir, ".test_more")
+	local := filepath.Join(dir, ".testrc.local")
+	files := map[string]string{
+		rc:      `. "$HOME/.test_aliases"`,
+		aliases: "source .test_more\nalias ll='ls -l'",
+		more:    `. "$HOME/.testrc"`,
+	}
+	for file, src := range files {
+		if err := os.WriteFile(file, []byte(src), 0o600); err != nil {
+			t.Fatalf("could not write rc file: %s", err)
+		}
+	}
+
+	want := []string{aliases, more, rc}
+	if have := sourcedFiles(rc, map[string]bool{}); !reflect.DeepEqual(want, have) {
+		t.Errorf("sourced files: want %v, have %v", want, have)
+	}
+
+	backend := &testEnvBackend{rcFiles: []string{rc, local}}
+	envBackends["test"] = backend
+	defer delete(envBackends, "test")
+	Cfg = SolCfg{Shell: "test"}
+
+	capture := func(wantCaptures int, why string) {
+		t.Helper()
+		if _, err := getCachedShellEnv(); err != nil {
+			t.Fatalf("could not get shell env: %s", err)
+		}
+		if backend.captures != wantCaptures {
+			t.Errorf("%s: want %d captures, have %d", why, wantCaptures, backend.captures)
+		}
+	}
+
+	capture(1, "first run")
+	capture(1, "cached")
+
+	cacheFile := envCacheFile("test")
+	info, err := os.Stat(cacheFile)
+	if err != nil {
+		t.Fatalf("could not stat env cache: %s", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("env cache is readable by others: %v", info.Mode().Perm())
+	}
+	cache, err := os.ReadFile(cacheFile)
+	if err != nil {
+		t.Fatalf("could not read env cache: %s", err)
+	}
+	if strings.Contains(string(cache), "hunter2") {
+		t.Errorf("env cache contains a variable's value")
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(more, later, later); err != nil {
+		t.Fatalf("could not touch sourced file: %s", err)
+	}
+	capture(2, "sourced file modified")
+
+	if err := os.WriteFile(local, []byte("alias la='ls -a'"), 0o600); err != nil {
+		t.Fatalf("could not write rc file: %s", err)
+	}
+	capture(3, "missing rc file created")
+	capture(3, "cached again")
+
+	t.Setenv("PATH", os.Getenv("PATH")+":"+dir)
+	capture(4, "PATH changed")
+
+	Cfg.RefreshEnv = true
+	capture(5, "refreshed")
+}
+
+func TestEnvSections(t *testing.T) {
+
+	marker := "SOL-0123456789abcdef"
+	sections := []envSection{{"aliases", "alias"}, {"funcs", "declare -f"}}
+
+	cases := []struct {
+		name    string
+		out     string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			"sections between markers",
+			"welcome!\n" +
+				marker + " begin aliases\nalias ll='ls -l'\n\x1b[0malias la='ls -a'\n" + marker + " end aliases 0\n" +
+				"stray output\n" +
+				marker + " begin funcs\nf () \n{ \n    :\n}\n" + marker + " end funcs 0\n",
+			map[string]string{"aliases": "alias ll='ls -l'\nalias la='ls -a'", "funcs": "f () \n{ \n    :\n}"},
+			false,
+		},
+		{
+			"status 1 with empty output",
+			marker + " begin aliases\n" + marker + " end aliases 1\n" +
+				marker + " begin funcs\n" + marker + " end funcs 0\n",
+			map[string]string{"aliases": "", "funcs": ""},
+			false,
+		},
+		{
+			"status 1 with output",
+			marker + " begin aliases\nalias: oops\n" + marker + " end aliases 1\n" +
+				marker + " begin funcs\n" + marker + " end funcs 0\n",
+			nil,
+			true,
+		},
+		{
+			"status 2",
+			marker + " begin aliases\n" + marker + " end aliases 2\n" +
+				marker + " begin funcs\n" + marker + " end funcs 0\n",
+			nil,
+			true,
+		},
+		{
+			"missing section",
+			marker + " begin aliases\n" + marker + " end aliases 0\n",
+			nil,
+			true,
+		},
+		{
+			"another marker",
+			"SOL-ffffffffffffffff begin aliases\nalias x=y\nSOL-ffffffffffffffff end aliases 0\n" +
+				marker + " begin aliases\n" + marker + " end aliases 0\n" +
+				marker + " begin funcs\n" + marker + " end funcs 0\n",
+			map[string]string{"aliases": "", "funcs": ""},
+			false,
+		},
+	}
+
+	for _, c := range cases {
+		have, err := parseEnvSections(marker, c.out, sections)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%s: want error, have %q", c.name, have)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: could not parse sections: %s", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(c.want, have) {
+			t.Errorf("%s: want %q, have %q", c.name, c.want, have)
+		}
+	}
+}
+
+func TestEnvNoise(t *testing.T) {
+
+	marker := "SOL-0123456789abcdef"
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	stdout := "config loaded\n" +
+		"\n" + marker + " rc /home/me/.bashrc\nhello from bashrc\n" +
+		"\n" + marker + " rc /home/me/.bash_aliases\n\x1b]0;my title\x07\n" +
+		"\n" + marker + " done\nnot from any dotfile\n"
+	stderr := "\n" + marker + " rc /home/me/.bashrc\n" +
+		"\n" + marker + " rc /home/me/.bash_aliases\n\x1b[33mwarning: deprecated\x1b[0m\n" +
+		"\n" + marker + " done\n"
+
+	if have := reportEnvNoise(marker, "/etc/startup", stdout, stderr); have != "" {
+		t.Errorf("last dotfile of finished capture: want none, have %s", have)
+	}
+	want := []string{
+		"/etc/startup printed output while capturing shell environment: config loaded",
+		"/home/me/.bashrc printed output while capturing shell environment: hello from bashrc",
+		"/home/me/.bash_aliases printed output while capturing shell environment: warning: deprecated",
+	}
+	have := []string{}
+	for _, entry := range hook.AllEntries() {
+		have = append(have, entry.Message)
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("want warnings %q, have %q", want, have)
+	}
+
+	// A capture that timed out stops partway through a dotfile.
+	hook.Reset()
+	stdout = "\n" + marker + " rc /home/me/.bashrc\n\n" + marker + " rc /home/me/.bash_aliases\n"
+	if have := reportEnvNoise(marker, "", stdout); have != "/home/me/.bash_aliases" {
+		t.Errorf("last dotfile of unfinished capture: want /home/me/.bash_aliases, have %s", have)
+	}
+	if len(hook.AllEntries()) > 0 {
+		t.Errorf("want no warnings, have %d", len(hook.AllEntries()))
+	}
+}
+
+func TestStripCtrlSeqs(t *testing.T) {
+
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"\x1b[1;32mok\x1b[0m", "ok"},
+		{"\x1b[2K\x1b[1Gprogress", "progress"},
+		{"\x1b]0;user@host: ~\x07prompt", "prompt"},
+		{"\x1b]8;;https://example.com\x1b\\link\x1b]8;;\x1b\\", "link"},
+		{"\x1b(Bplain", "plain"},
+		{"bell\x07 and\r return", "bell and return"},
+		{"tab\tstays", "tab\tstays"},
+	}
+
+	for _, c := range cases {
+		if have := stripCtrlSeqs(c.in); have != c.want {
+			t.Errorf("stripCtrlSeqs(%q): want %q, have %q", c.in, c.want, have)
+		}
+	}
+}
+
+func TestEnvDiff(t *testing.T) {
+
+	src := `ll /tmp | mytool --fast && deploy "$(whoami)"; ll; cd /tmp`
+
+	wantCmds := []string{"ll", "mytool", "deploy", "whoami", "cd"}
+	haveCmds, err := programCmds(src)
+	if err != nil {
+		t.Fatalf("could not get program commands: %s", err)
+	}
+	if !reflect.DeepEqual(wantCmds, haveCmds) {
+		t.Errorf("want commands %q, have %q", wantCmds, haveCmds)
+	}
+
+	a := NewStaticEnvProvider(ShellEnv{
+		AliasDefs: map[string]string{"ll": "ls -l"},
+		FuncDefs:  map[string]string{"deploy": "make deploy ENV=\"$1\""},
+		Builtins:  []string{"cd"},
+		VarDefs:   map[string]string{"PATH": "testdata/env-bin"},
+	})
+	b := NewStaticEnvProvider(ShellEnv{
+		AliasDefs: map[string]string{"ll": "ls -la"},
+		Builtins:  []string{"cd"},
+		VarDefs:   map[string]string{"PATH": "testdata/nonexistent"},
+	})
+
+	want := []CmdDiff{
+		{"ll", "alias: ls -l", "alias: ls -la"},
+		{"mytool", "file: testdata/env-bin/mytool", "missing"},
+		{"deploy", "function: make deploy ENV=\"$1\"", "missing"},
+	}
+	have, err := DiffShellEnvs(src, &a.Env, &b.Env)
+	if err != nil {
+		t.Fatalf("could not diff envs: %s", err)
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("want diffs %q, have %q", want, have)
+	}
+
+	// An environment doesn't differ from itself.
+	have, err = DiffShellEnvs(src, &a.Env, &a.Env)
+	if err != nil {
+		t.Fatalf("could not diff envs: %s", err)
+	}
+	if len(have) > 0 {
+		t.Errorf("want no diffs, have %q", have)
+	}
+}
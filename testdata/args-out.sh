@@ -1,4 +1,5 @@
 curl \
     -sv \
     -A 'Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/127.0.0.0 Safari/537.36' \
-    -d 'this=that' https://www.google.com
\ No newline at end of file
+    -d 'this=that' \
+    https://www.google.com
\ No newline at end of file
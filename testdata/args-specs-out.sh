@@ -0,0 +1,14 @@
+tar \
+    -xzvf archive.tgz \
+    -C /tmp \
+    --exclude '*.log' \
+    etc/hosts \
+    etc/passwd && find \
+    . \
+    -type f \
+    -name '*.go' \
+    -exec grep -l TODO {} \; \
+    -print && mytool \
+    -o out.txt \
+    -v \
+    in.txt
\ No newline at end of file
@@ -0,0 +1 @@
+total=$(( ${#files[@]}*blk+${pad:-0}-(hdr*2) )); (( total>max ? total-- : (total=max) )); for ((i=0;i<total;i++)); do echo $i; done; for ((i=0, j=${#arr[@]}-1; i<j; i++, j--)); do echo ${arr[i]}; done
\ No newline at end of file
@@ -0,0 +1 @@
+arr=(one two "three four"); declare -A m=([k]=v [k2]="v 2"); export A=1 B=2 C=3; local -r x=(a b) y=1
\ No newline at end of file
@@ -1,15 +1,19 @@
 cat urls.lst |
     parallel \
-        -j 4 'curl \
+        -j 4 \
+        'curl \
             -sv \
             -d {"this":"that"} \
-            -H "Content-Type: application/json" {} \
+            -H "Content-Type: application/json" \
+            {} \
             2>>out.log |
             grep \
-                -v "error"' |
+                -v \
+                "error"' |
     jq \
         -s \
-        --arg key val 'map({ 
+        --arg key val \
+        'map({ 
                 key: $val
             } + 
             { 
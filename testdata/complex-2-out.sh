@@ -1,3 +1,3 @@
-echo \
-    -n 'this string with a few words' |
+echo -n \
+    'this string with a few words' |
     wc -l | sed -E 's/^ +//'
\ No newline at end of file
@@ -0,0 +1,21 @@
+# {}: input
+parallel \
+    -j 2 \
+    -k \
+    echo {} \
+    ::: a b
+mlr --icsv --ojson cut -f a,b \
+    then sort -f a x.csv
+ffmpeg \
+    -i a.mp4 \
+    -i b.mp4 \
+    -filter_complex "[0:v][1:v]hstack" \
+    out.mp4
+find \
+    . \
+    -name "*.go" \
+    -print0 | xargs -0 -n 1 -P 4 sh -c 'gofmt \
+        -l \
+        "$1"' _
+[ -f a \
+    -a -d b ] && test -n "$x"
\ No newline at end of file
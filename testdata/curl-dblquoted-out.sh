@@ -0,0 +1,11 @@
+curl \
+    -X POST \
+    -d "{\"name\": \"$USER\"}" \
+    https://api.example.com/v1/users
+curl \
+    --data-raw "{
+            \"name\": \"web\",
+            \"cmd\": \"echo \\\"hi\\\" \$HOME\",
+            \"sep\": \"a\nb\"
+        }" \
+    https://api.example.com/v1/apps
\ No newline at end of file
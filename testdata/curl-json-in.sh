@@ -0,0 +1,2 @@
+curl -sS -X POST -H 'Content-Type: application/json' -H 'Accept: application/json' -u admin:secret -d '{"name": "web", "tags": ["a", "b"], "replicas": 3}' https://api.example.com/v1/apps
+curl -s -t TTYPE=vt100 -t XDISPLOC=host:0 telnet://host
\ No newline at end of file
@@ -0,0 +1 @@
+AWS_PROFILE=prod AWS_REGION=eu-west-1 deploy prod && ping example.com
\ No newline at end of file
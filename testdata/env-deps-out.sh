@@ -0,0 +1,13 @@
+# AWS_PROFILE is set in environment
+# AWS_REGION is set in environment
+# ping is function: pong "$@"
+# pong is function: ping "$@"
+# nonstd cmd: testdata/env-bin/mytool
+# upload is function: mytool push "$1"
+# mk is alias: mytool -j8
+# build is function: mk all
+# deploy is function: build && upload "$1"
+AWS_PROFILE=prod \
+    AWS_REGION=eu-west-1 \
+    deploy prod &&
+    ping example.com
\ No newline at end of file
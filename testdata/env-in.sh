@@ -0,0 +1 @@
+AWS_PROFILE=prod mytool -v | grep x && mkcd out && ll | gco main
\ No newline at end of file
@@ -0,0 +1 @@
+nosuch --fast | mytool | jq .
\ No newline at end of file
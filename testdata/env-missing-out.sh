@@ -0,0 +1,6 @@
+# missing: jq
+# nonstd cmd: testdata/env-bin/mytool
+# missing: nosuch
+nosuch --fast |
+    mytool |
+    jq .
\ No newline at end of file
@@ -0,0 +1,15 @@
+# AWS_PROFILE is set in environment
+# missing: git
+# gco is abbr: git checkout
+# missing: ls
+# ll is alias: ls -l
+# missing: mkdir
+# mkcd is function: mkdir -p "$1" && cd "$1"
+# nonstd cmd dir: testdata/env-bin/grep
+# nonstd cmd: testdata/env-bin/mytool
+AWS_PROFILE=prod \
+    mytool -v |
+    grep x &&
+    mkcd out &&
+    ll |
+    gco main
\ No newline at end of file
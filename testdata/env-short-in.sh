@@ -0,0 +1 @@
+lh && ls --all --human-readable /tmp
\ No newline at end of file
@@ -0,0 +1,4 @@
+# missing: ls
+# lh is alias: ls --all --human-readable
+lh &&
+    ls -ah /tmp
\ No newline at end of file
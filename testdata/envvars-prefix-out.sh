@@ -0,0 +1,8 @@
+FOO=1 \
+    BAR="x y" \
+    AWS_PROFILE=prod \
+    aws s3 ls s3://bucket |
+    LC_ALL=C \
+        sort
+A=1 \
+    B=2
\ No newline at end of file
@@ -0,0 +1 @@
+cp "${src:-${DEFAULT_SRC:-$(pwd)}}" "${dest//\//_}/${name%%.*}.${ext,,}"; echo "${#files[@]} files, first: ${files[0]:0:8}"
\ No newline at end of file
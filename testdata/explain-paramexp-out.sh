@@ -0,0 +1,11 @@
+# ${src:-${DEFAULT_SRC:-$(pwd)}}: src, or ${DEFAULT_SRC:-$(pwd)} if unset or empty
+# ${DEFAULT_SRC:-$(pwd)}: DEFAULT_SRC, or $(pwd) if unset or empty
+# ${dest//\//_}: dest with every substring matching / replaced by _
+# ${name%%.*}: name without the longest suffix matching .*
+# ${ext,,}: ext lowercased
+cp "${src:-${DEFAULT_SRC:-$(
+    pwd
+)}}" "${dest//\//_}/${name%%.*}.${ext,,}"
+# ${#files[@]}: number of elements in files
+# ${files[0]:0:8}: substring of files[0] from offset 0 of length 8
+echo "${#files[@]} files, first: ${files[0]:0:8}"
\ No newline at end of file
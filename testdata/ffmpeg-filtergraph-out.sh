@@ -0,0 +1,8 @@
+ffmpeg \
+    -y \
+    -ss 10 -i in.mp4 \
+    -i logo.png \
+    -filter_complex '[0:v]scale=1280:-1,
+            fps=30[bg];
+        [bg][1:v]overlay=10:10[out]' \
+    -map '[out]' -map 0:a -c:v libx264 -crf 23 out.mp4
\ No newline at end of file
@@ -0,0 +1,12 @@
+build() {
+    make -j4
+}
+(
+    cd /src/a && build
+) &
+{
+    date
+} >status.log
+echo $( (
+    uptime
+))
\ No newline at end of file
@@ -0,0 +1 @@
+tar -xzvf x.tgz -C /opt && rsync -avzP --delete src/ host:dst/ && git -C repo commit -am 'fix it' && head -n5 f | grep -vE -e -foo && ls -lah
\ No newline at end of file
@@ -0,0 +1,8 @@
+# tar -xzvf: --extract --gzip --verbose --file
+# tar -C: --directory
+# rsync -avzP: --archive --verbose --compress --partial --progress
+# git commit -am: --all --message
+# head -n5: --lines 5
+# grep -vE: --invert-match --extended-regexp
+# grep -e: --regexp
+tar -xzvf x.tgz -C /opt && rsync -avzP --delete src/ host:dst/ && git -C repo commit -am 'fix it' && head -n5 f | grep -vE -e -foo && ls -lah
\ No newline at end of file
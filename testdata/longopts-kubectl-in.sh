@@ -0,0 +1 @@
+kubectl logs -f -p mypod -c app && kubectl apply -f deploy.yaml -n prod && kubectl exec -it mypod -- sh && git log -P -n5 x
\ No newline at end of file
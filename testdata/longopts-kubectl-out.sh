@@ -0,0 +1 @@
+kubectl logs --follow --previous mypod --container app && kubectl apply --filename deploy.yaml --namespace prod && kubectl exec --stdin --tty mypod -- sh && git log -P --max-count 5 x
\ No newline at end of file
@@ -0,0 +1 @@
+tar --extract --gzip --verbose --file x.tgz --directory /opt && rsync --archive --verbose --compress --partial --progress --delete src/ host:dst/ && git -C repo commit --all --message 'fix it' && head --lines 5 f | grep --invert-match --extended-regexp --regexp -foo && ls -lah
\ No newline at end of file
@@ -0,0 +1 @@
+find . -name "*.log" | xargs -L 1 -r -P 4 gzip
\ No newline at end of file
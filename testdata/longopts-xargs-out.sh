@@ -0,0 +1 @@
+find . -name "*.log" | xargs -L 1 --no-run-if-empty --max-procs 4 gzip
\ No newline at end of file
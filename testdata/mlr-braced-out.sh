@@ -0,0 +1,6 @@
+mlr --icsv --ojson put '${total cost} = $a * $b;
+    @{grand total} += ${total cost};
+    if ($c > 1) {
+        $d = "{x}"
+    }' \
+    then sort -nr 'total cost' x.csv
\ No newline at end of file
@@ -0,0 +1,2 @@
+mlr -i csv -o json put '$a = 1; $b = 2' then head -n 2 data.csv
+mlr --io json --mfrom a.json b.json -- filter '$x > 1; $y < 2' then sort -f x
\ No newline at end of file
@@ -0,0 +1 @@
+mlr --icsv --ojson cut -f a,b,x then put '$y = $a . "; " . $b; if ($x > 3) { $big = true } else { $big = false }' then filter '$big' then sort -nr x data.csv
\ No newline at end of file
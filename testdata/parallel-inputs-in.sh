@@ -0,0 +1 @@
+parallel -j 4 --colsep , -a extra.txt 'curl -s -o {1/.}.out {2}' ::: a b c :::+ 1 2 3 :::: hosts.txt
\ No newline at end of file
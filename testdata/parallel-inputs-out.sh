@@ -0,0 +1,10 @@
+# {1/.}: basename of input 1 without extension
+# {2}: input 2
+parallel \
+    -j 4 \
+    --colsep , \
+    -a extra.txt \
+    'curl -s -o {1/.}.out {2}' \
+    ::: a b c \
+    :::+ 1 2 3 \
+    :::: hosts.txt
\ No newline at end of file
@@ -0,0 +1 @@
+parallel -I @@ 'convert @@ {/.}.png && echo {#} {%} {//} {= s/a/b/ =}' ::: *.jpg
\ No newline at end of file
@@ -0,0 +1,10 @@
+# @@: input
+# {/.}: basename of input without extension
+# {#}: job sequence number
+# {%}: job slot number
+# {//}: dirname of input
+# {= s/a/b/ =}: input modified by the perl expression s/a/b/
+parallel \
+    -I @@ \
+    'convert @@ {/.}.png && echo {#} {%} {//} {= s/a/b/ =}' \
+    ::: *.jpg
\ No newline at end of file
@@ -1,6 +1,8 @@
 cat urls.txt | parallel \
     -j 4 \
-    --res out.res 'curl \
+    --res out.res \
+    'curl \
         -s \
         -v \
-        -o /dev/null {}'
\ No newline at end of file
+        -o /dev/null \
+        {}'
\ No newline at end of file
@@ -0,0 +1,7 @@
+sh -c "$cmd"
+find . -print0 |
+    xargs -0 sh -c "$cmd"
+parallel -j2 "$cmd" ::: a
+sh -c "echo \"$1\"" _ x
+sh -c "cd /tmp &&
+    ls -l" _
\ No newline at end of file
@@ -0,0 +1,4 @@
+find . -type f |
+    xargs -d '\n' -n1 grep -l 'foo|bar' |
+    xargs -P4 -I@ sh -c 'gzip -9 @ &&
+        mv @.gz /archive'
\ No newline at end of file
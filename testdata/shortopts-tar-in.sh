@@ -0,0 +1 @@
+tar --extract --gzip --verbose --file x.tgz --directory /opt && rsync --archive --verbose --compress --partial --progress --delete src/ host:dst/ && git -C repo commit --all --message 'fix it' && head --lines=5 f | grep --invert-match --extended-regexp --regexp -foo && cp --recursive -v a b && ls -l -a -h && sed --in-place=.bak -n -e p f && docker run --interactive --tty --rm --publish 80:80 img && find . -name x
\ No newline at end of file
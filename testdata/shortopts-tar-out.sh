@@ -0,0 +1 @@
+tar -xzvf x.tgz -C /opt && rsync -avz --partial --progress --delete src/ host:dst/ && git -C repo commit -am 'fix it' && head -n 5 f | grep -vEe -foo && cp --recursive -v a b && ls -l -ah && sed --in-place=.bak -ne p f && docker run -it --rm -p 80:80 img && find . -name x
\ No newline at end of file
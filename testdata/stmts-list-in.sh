@@ -0,0 +1 @@
+cd /tmp; make clean; make -j4 & tail -f build.log & wait
\ No newline at end of file
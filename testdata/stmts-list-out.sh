@@ -0,0 +1,5 @@
+cd /tmp
+make clean
+make -j4 &
+tail -f build.log &
+wait
\ No newline at end of file
@@ -0,0 +1,5 @@
+[[ -f $a &&
+    ($b == x* ||
+        -z $c) ]] && [ -d "$d" \
+    -a \( "$e" = y \
+        -o -n "$f" \) ] && echo ok
\ No newline at end of file
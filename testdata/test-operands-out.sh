@@ -0,0 +1,8 @@
+[ "$x" = -o ] && test -o pipefail && echo ok
+[ -a /etc/passwd \
+    -o -d /tmp ] && echo ok
+[ "$a" \
+    -a "$b" \
+    -o ! -n "$c" ] && echo ok
+test "$a" != -a \
+    -o \( -a x \) && echo ok
\ No newline at end of file
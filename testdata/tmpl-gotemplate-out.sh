@@ -0,0 +1,5 @@
+docker inspect --format '{{range .Mounts}}
+        {{- .Source}} {{end}}
+    {{- if .State.Running}}
+        {{- .Name}}
+    {{- else}}stopped{{end}}' web
\ No newline at end of file
@@ -0,0 +1,4 @@
+kubectl get pods -o jsonpath='{.a}
+{.b}'
+docker ps --format '{{.ID}}
+{{- if .Names}}{{.Names}}{{end}}'
\ No newline at end of file
@@ -0,0 +1,142 @@
+package sol
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Returns whether a command is `test` or `[`.
+func isTestCmd(x *syntax.CallExpr) bool {
+	if len(x.Args) == 0 {
+		return false
+	}
+	cmd := getCmdVal(*x)
+	return cmd == "test" || cmd == "["
+}
+
+// Unary and binary `test`/`[` operators.
+var testUnaryOps = []string{"-a", "-b", "-c", "-d", "-e", "-f", "-g", "-h", "-k", "-n", "-o", "-p", "-r", "-s", "-t", "-u", "-v", "-w", "-x", "-z", "-G", "-L", "-N", "-O", "-R", "-S"}
+var testBinaryOps = []string{"=", "==", "!=", "<", ">", "=~", "-eq", "-ne", "-lt", "-le", "-gt", "-ge", "-ef", "-nt", "-ot"}
+
+// Returns the indices of the `-a` and `-o` arguments of a `test`/`[`
+// expression that join two tests together. The same words are also unary
+// operators (e.g., `-a file` or `-o pipefail`) or plain strings (e.g., `[ "$x"
+// = -o ]`), so they're only connectives where they follow a complete test.
+func testConnectives(x *syntax.CallExpr) []int {
+	conns := []int{}
+	end := len(x.Args)
+	if getCmdVal(*x) == "[" {
+		end--
+	}
+	vals := []string{}
+	for _, arg := range x.Args[:end] {
+		val, _ := wordVal(arg)
+		vals = append(vals, val)
+	}
+
+	complete := false
+	for a := 1; a < end; a++ {
+		if complete {
+			switch {
+			case testParen(x.Args[a]) == ")":
+			case vals[a] == "-a" || vals[a] == "-o":
+				conns = append(conns, a)
+				complete = false
+			default:
+				complete = false
+				a--
+			}
+			continue
+		}
+		switch {
+		case vals[a] == "!" || testParen(x.Args[a]) == "(":
+			continue
+		case a+2 < end && slices.Contains(testBinaryOps, vals[a+1]):
+			a += 2
+		case a+1 < end && slices.Contains(testUnaryOps, vals[a]):
+			a++
+		}
+		complete = true
+	}
+	return conns
+}
+
+// Returns changes that break a `test`/`[` expression before each `-a` and
+// `-o` connective, keeping each unary and binary test together.
+func fmtTestArgs(x *syntax.CallExpr) []change {
+	if !isTestCmd(x) {
+		return nil
+	}
+	return breakArgs(x, testConnectives(x))
+}
+
+// Returns whether a `test`/`[` argument is an opening or closing parenthesis,
+// e.g., `\(` or `')'`.
+func testParen(w *syntax.Word) string {
+	val, _ := wordVal(w)
+	val = strings.TrimPrefix(val, "\\")
+	if val == "(" || val == ")" {
+		return val
+	}
+	return ""
+}
+
+// Indents the lines of a line-broken test expression that fall within
+// parentheses, one level per level of nesting. The shell printer always puts
+// continuation lines at the same indentation, so this has to happen after the
+// program is printed.
+func indentTests(src string) (string, error) {
+
+	pp, err := parseProg(src)
+	if err != nil {
+		return "", fmt.Errorf("could not parse program: %w", err)
+	}
+
+	// Count how many levels each line needs to be indented by.
+	lineIdts := map[uint]int{}
+	idtLines := func(open, close syntax.Pos) {
+		for l := open.Line() + 1; l <= close.Line(); l++ {
+			lineIdts[l]++
+		}
+	}
+	syntax.Walk(pp, func(node syntax.Node) bool {
+		switch x := node.(type) {
+
+		case *syntax.ParenTest:
+			idtLines(x.Lparen, x.Rparen)
+
+		case *syntax.CallExpr:
+			if !isTestCmd(x) {
+				break
+			}
+			opens := []syntax.Pos{}
+			for _, arg := range x.Args[1:] {
+				switch testParen(arg) {
+				case "(":
+					opens = append(opens, arg.Pos())
+				case ")":
+					if len(opens) > 0 {
+						idtLines(opens[len(opens)-1], arg.Pos())
+						opens = opens[:len(opens)-1]
+					}
+				}
+			}
+		}
+		return true
+	})
+	if len(lineIdts) == 0 {
+		return src, nil
+	}
+
+	srcLines := strings.Split(src, "\n")
+	for l, idt := range lineIdts {
+		if int(l) <= len(srcLines) {
+			srcLines[l-1] = strings.Repeat("    ", idt) + srcLines[l-1]
+		}
+	}
+
+	return strings.Join(srcLines, "\n"), nil
+}
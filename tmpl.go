@@ -0,0 +1,273 @@
+package sol
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Commands that take Go template arguments.
+var tmplCmds = []string{"docker", "podman", "kubectl", "oc", "gh"}
+
+// Options that take a template, e.g., `docker inspect --format`, `kubectl get
+// -o`, and `gh api --template`.
+var tmplOpts = []string{"-f", "--format", "-t", "--template", "-o", "--output"}
+
+// Output format prefixes of Go templates (e.g., `-o go-template=...`).
+var tmplPrefixes = []string{"go-template=", "template="}
+
+// A template argument. The template itself starts at `Off` within the value
+// of a single-quoted string.
+type tmplArg struct {
+	Part *syntax.SglQuoted
+	Off  int
+}
+
+// Returns the Go template arguments of a command. JSONPath templates (e.g.,
+// `kubectl -o jsonpath=...`) never contain `{{`, so they're never mistaken for
+// Go templates.
+func findTmplArgs(x *syntax.CallExpr) []tmplArg {
+
+	args := []tmplArg{}
+	cmd := getCmdVal(*x)
+	found := false
+	for _, tc := range tmplCmds {
+		if cmd == tc {
+			found = true
+		}
+	}
+	if !found {
+		return args
+	}
+
+	for a := 1; a < len(x.Args); a++ {
+		val, ok := wordVal(x.Args[a])
+		if !ok {
+			continue
+		}
+
+		// Find the option's value, whether it's separate (`-o go-template=...`),
+		// attached with `=` (`-o=go-template=...`), or attached directly
+		// (`-ogo-template=...`).
+		word := x.Args[a]
+		optVal := ""
+		opt := ""
+		for _, to := range tmplOpts {
+			if val == to && a+1 < len(x.Args) {
+				opt = to
+				word = x.Args[a+1]
+				optVal, ok = wordVal(word)
+				a++
+				break
+			} else if strings.HasPrefix(val, to+"=") {
+				opt = to
+				optVal = strings.TrimPrefix(val, to+"=")
+				break
+			} else if len(to) == 2 && strings.HasPrefix(val, to) && len(val) > 2 {
+				opt = to
+				optVal = val[2:]
+				break
+			}
+		}
+		if opt == "" || !ok {
+			continue
+		}
+
+		// An output format is only a template if it says so.
+		tmpl := optVal
+		prefixed := false
+		for _, prefix := range tmplPrefixes {
+			if strings.HasPrefix(optVal, prefix) {
+				prefixed = true
+				tmpl = strings.TrimPrefix(optVal, prefix)
+			}
+		}
+		if (opt == "-o" || opt == "--output") && !prefixed || !strings.Contains(tmpl, "{{") {
+			continue
+		}
+
+		// The template needs to be entirely within a trailing single-quoted
+		// string so that line breaks can be inserted into it.
+		part, ok := word.Parts[len(word.Parts)-1].(*syntax.SglQuoted)
+		if !ok {
+			continue
+		}
+		off := len(part.Value) - len(tmpl)
+		if off < 0 {
+			continue
+		}
+		args = append(args, tmplArg{part, off})
+	}
+
+	return args
+}
+
+// A piece of a Go template: either an action (e.g., `{{.Name}}`) or the
+// literal text between actions.
+type tmplTok struct {
+	Action bool
+	Str    string
+}
+
+// Splits a Go template into actions and text. Action delimiters inside quoted
+// strings are ignored.
+func tokenizeTmpl(tmpl string) []tmplTok {
+	toks := []tmplTok{}
+	for len(tmpl) > 0 {
+		start := strings.Index(tmpl, "{{")
+		if start < 0 {
+			toks = append(toks, tmplTok{false, tmpl})
+			break
+		}
+		if start > 0 {
+			toks = append(toks, tmplTok{false, tmpl[:start]})
+		}
+
+		end := len(tmpl)
+		var quote byte
+		for i := start + 2; i < len(tmpl); i++ {
+			c := tmpl[i]
+			if quote != 0 {
+				if c == '\\' && quote != '`' {
+					i++
+				} else if c == quote {
+					quote = 0
+				}
+			} else if c == '"' || c == '`' || c == '\'' {
+				quote = c
+			} else if strings.HasPrefix(tmpl[i:], "}}") {
+				end = i + 2
+				break
+			}
+		}
+		toks = append(toks, tmplTok{true, tmpl[start:end]})
+		tmpl = tmpl[end:]
+	}
+
+	return toks
+}
+
+// Returns the block keyword (e.g., `range` or `end`) that an action starts
+// with, if any.
+func tmplKeyword(action string) string {
+	inner := strings.TrimPrefix(strings.Trim(action, "{}"), "-")
+	fields := strings.Fields(inner)
+	if len(fields) == 0 {
+		return ""
+	}
+	switch kw := fields[0]; kw {
+	case "range", "end", "if", "with", "else", "define", "block":
+		return kw
+	}
+	return ""
+}
+
+var goTmplJoinRe = regexp.MustCompile(`\}\}\n[ ]*\{\{- `)
+
+// Breaks a Go template onto multiple lines around `range`, `if`, `with`,
+// `else`, and `end` actions, indenting the blocks in between. Line breaks only
+// go between adjacent actions, and the action after each break gets a `{{-`
+// trim marker so that the template's output doesn't change.
+func explodeTmpl(tmpl string) string {
+	out := ""
+	depth := 0
+	prevKw := ""
+	prevAction := false
+	for _, tok := range tokenizeTmpl(tmpl) {
+		if !tok.Action {
+			out += tok.Str
+			prevAction = false
+			continue
+		}
+
+		kw := tmplKeyword(tok.Str)
+		lnDepth := depth
+		switch kw {
+		case "end":
+			depth--
+			lnDepth = depth
+		case "else":
+			lnDepth = depth - 1
+		case "range", "if", "with", "define", "block":
+			depth++
+		}
+		if lnDepth < 0 {
+			lnDepth = 0
+		}
+
+		action := tok.Str
+		if prevAction && (kw != "" || prevKw != "") {
+			if !strings.HasPrefix(action, "{{-") {
+				action = "{{- " + strings.TrimPrefix(action, "{{")
+			}
+			out += "\n" + strings.Repeat("    ", lnDepth)
+		}
+		out += action
+		prevKw = kw
+		prevAction = true
+	}
+	return out
+}
+
+// Reverses `explodeTmpl`. Only a template that's laid out exactly the way
+// `explodeTmpl` would lay it out is put back onto a single line, so that line
+// breaks written by hand are left alone.
+func implodeTmpl(tmpl string) string {
+	joined := goTmplJoinRe.ReplaceAllString(tmpl, "}}{{")
+	if joined == tmpl || !sameLines(explodeTmpl(joined), tmpl) {
+		return tmpl
+	}
+	return joined
+}
+
+// Reports whether two strings have the same lines, regardless of how they're
+// indented.
+func sameLines(a string, b string) bool {
+	aLines := strings.Split(a, "\n")
+	bLines := strings.Split(b, "\n")
+	if len(aLines) != len(bLines) {
+		return false
+	}
+	for l := range aLines {
+		if strings.TrimLeft(aLines[l], " ") != strings.TrimLeft(bLines[l], " ") {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns changes that break Go template arguments around their blocks (or,
+// if imploding, back onto a single line).
+func fmtTmpl(x *syntax.CallExpr, implode bool, src string) ([]change, error) {
+
+	chgs := []change{}
+	for _, ta := range findTmplArgs(x) {
+		tmpl := ta.Part.Value[ta.Off:]
+		tmplMod := ""
+		if implode {
+			tmplMod = implodeTmpl(tmpl)
+		} else {
+
+			// Indenting would change any literal text that already spans
+			// multiple lines.
+			if strings.Contains(tmpl, "\n") {
+				continue
+			}
+			var err error
+			tmplMod, err = indent(explodeTmpl(tmpl), lineIndent(src, ta.Part.Pos())+4, true)
+			if err != nil {
+				return chgs, fmt.Errorf("could not indent template: %w", err)
+			}
+		}
+		if tmplMod == tmpl {
+			continue
+		}
+		pos := int(ta.Part.Pos().Offset()) + 1 + ta.Off
+		end := int(ta.Part.End().Offset()) - 1
+		chgs = append(chgs, change{pos, end, tmplMod})
+	}
+
+	return chgs, nil
+}
@@ -77,6 +77,15 @@ func fmtProg(src string) (string, error) {
 	return treeToStr(pp, syntax.Indent(4)), nil
 }
 
+// Returns the input program on a single line, without changing it otherwise.
+func oneLineProg(src string) (string, error) {
+	pp, err := parseProg(src)
+	if err != nil {
+		return "", fmt.Errorf("could not parse program: %w", err)
+	}
+	return treeToStr(pp, syntax.SingleLine(true)), nil
+}
+
 func enforceMaxWidth(src string) (string, error) {
 	srcLines := strings.Split(src, "\n")
 	srcWide := ""
@@ -254,33 +263,30 @@ func fmtSh(x *syntax.CallExpr, implode bool, src string) ([]change, error) {
 	chgs := []change{}
 	shArgIdx := 0
 	cmdStrArgIdx := 0
-	if cmd == "xargs" || cmd == "parallel" {
-
-		// First, try looking for a shell being explicitly invoked.
-		// e.g., `xargs bash -c 'echo {}'`
-		for a, arg := range x.Args {
-			if shArgIdx > 0 {
-				break
-			}
-			part := arg.Parts[0]
-			if fmt.Sprintf("%T", part) == "*syntax.Lit" && strings.HasSuffix(part.(*syntax.Lit).Value, "sh") {
-				shArgIdx = a
-			}
-		}
+	utilIdx := 0
+	rpl := ""
+	if cmd == "parallel" {
+		pa := parseParallel(x)
+		utilIdx = pa.Tmpl
+		rpl = pa.Rpl
+	} else if cmd == "xargs" {
+		xa := parseXargs(x)
+		utilIdx = xa.Util
+		rpl = xa.Rpl
+	}
 
-		// Next, try looking for a probable command string.
-		// e.g., `xargs 'echo {}'`
-		if shArgIdx == 0 {
-			for a := len(x.Args) - 1; a >= 0; a-- {
-				if cmdStrArgIdx > 0 {
-					break
-				}
-				part := x.Args[a].Parts[0]
-				if fmt.Sprintf("%T", part) == "*syntax.SglQuoted" || fmt.Sprintf("%T", part) == "*syntax.DblQuoted" {
-					cmdStrArgIdx = a
-					atCmdStr = true
-				}
+	// The utility is either a shell being explicitly invoked (e.g., `xargs
+	// bash -c 'echo {}'`) or a probable command string (e.g., `parallel 'echo
+	// {}' ::: a b`).
+	if utilIdx > 0 {
+		switch p := x.Args[utilIdx].Parts[0].(type) {
+		case *syntax.Lit:
+			if strings.HasSuffix(p.Value, "sh") {
+				shArgIdx = utilIdx
 			}
+		case *syntax.SglQuoted, *syntax.DblQuoted:
+			cmdStrArgIdx = utilIdx
+			atCmdStr = true
 		}
 	}
 
@@ -324,11 +330,29 @@ func fmtSh(x *syntax.CallExpr, implode bool, src string) ([]change, error) {
 							cmdStr = x.Value
 							lineNumStr = strings.Split(x.Pos().String(), ":")[0]
 						case *syntax.DblQuoted:
-							cmdStr = x.Parts[0].(*syntax.Lit).Value
-							lineNumStr = strings.Split(x.Parts[0].Pos().String(), ":")[0]
+
+							// A double-quoted string with expansions in it
+							// (e.g., `sh -c "$cmd"`) isn't known until it
+							// runs, so we leave it be.
+							if len(x.Parts) != 1 {
+								return false
+							}
+							lit, ok := x.Parts[0].(*syntax.Lit)
+							if !ok {
+								return false
+							}
+							cmdStr = lit.Value
+							lineNumStr = strings.Split(lit.Pos().String(), ":")[0]
+							return false
 						}
 						return true
 					})
+
+					// Not a quoted string that we can format (e.g., `sh -c
+					// $cmd` or `sh -c "$cmd"`).
+					if lineNumStr == "" {
+						return chgs, nil
+					}
 					lineNum, err := strconv.Atoi(lineNumStr)
 					if err != nil {
 						err = fmt.Errorf("could not get column value: %w", err)
@@ -345,6 +369,7 @@ func fmtSh(x *syntax.CallExpr, implode bool, src string) ([]change, error) {
 						}
 					}
 
+					cmdStr, unprotect := protectRpl(cmdStr, rpl)
 					cmdStrMod := ""
 					if implode {
 						cmdStrMod, err = ImplodeSh(cmdStr)
@@ -354,10 +379,14 @@ func fmtSh(x *syntax.CallExpr, implode bool, src string) ([]change, error) {
 					if err != nil {
 						return chgs, fmt.Errorf("could not format shell: %w", err)
 					}
+					cmdStrMod = unprotect(cmdStrMod)
 					pos := int(part.Pos().Offset())
 					end := int(part.End().Offset())
 					chgs = append(chgs, change{pos + 1, end - 1, cmdStrMod})
-					break
+
+					// Anything after the command string (e.g., positional
+					// parameters or parallel input sources) is left alone.
+					return chgs, nil
 				}
 			}
 		}
@@ -428,3 +457,146 @@ func fmtJq(x *syntax.CallExpr, implode bool, src string) ([]change, error) {
 
 	return chgs, nil
 }
+
+// Returns the value of a word made up only of literals and simple quoted
+// strings (e.g., `-o`, `'{}'`, or `--format='{{.Name}}'`). The second return
+// value is false if the word contains any expansions.
+func wordVal(w *syntax.Word) (string, bool) {
+	val := ""
+	for _, part := range w.Parts {
+		switch p := part.(type) {
+		case *syntax.Lit:
+			val += p.Value
+		case *syntax.SglQuoted:
+			val += p.Value
+		case *syntax.DblQuoted:
+			for _, dqPart := range p.Parts {
+				lit, ok := dqPart.(*syntax.Lit)
+				if !ok {
+					return "", false
+				}
+				val += lit.Value
+			}
+		default:
+			return "", false
+		}
+	}
+	return val, true
+}
+
+// Returns changes that insert a line break before each of the specified
+// arguments.
+func breakArgs(x *syntax.CallExpr, idxs []int) []change {
+	chgs := []change{}
+	for _, idx := range idxs {
+		pos := int(x.Args[idx].Pos().Offset())
+		chgs = append(chgs, change{pos, pos, "\\\n"})
+	}
+	return chgs
+}
+
+// Returns the number of spaces that the line containing the specified
+// position is indented by.
+func lineIndent(src string, pos syntax.Pos) int {
+	line := strings.Split(src, "\n")[pos.Line()-1]
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// Returns whether a statement is a command with prefix assignments, e.g.,
+// `LC_ALL=C sort`.
+func hasAssigns(stmt *syntax.Stmt) bool {
+	x, ok := stmt.Cmd.(*syntax.CallExpr)
+	return ok && len(x.Assigns) > 0
+}
+
+// Returns changes that put each variable assignment of a `declare`, `export`,
+// `local`, etc. on its own line, leaving any options (e.g., `-r`) in place.
+// A single assignment stays on the same line as the command.
+func breakAssigns(assigns []*syntax.Assign) []change {
+	chgs := []change{}
+	for _, as := range assigns {
+		if as.Name == nil {
+			continue
+		}
+		pos := int(as.Pos().Offset())
+		chgs = append(chgs, change{pos, pos, "\\\n"})
+	}
+	if len(chgs) < 2 {
+		return []change{}
+	}
+	return chgs
+}
+
+// Puts comments above the statements of a program, one per line. Comments
+// for a node go above the innermost statement that starts a line and
+// contains it (e.g., `a` in `a; b`, or `if` in `if a; then b; fi`), and the
+// same comment is never repeated for a statement.
+func commentStmts(src string, cmtNode func(syntax.Node) []string) (string, error) {
+
+	pp, err := parseProg(src)
+	if err != nil {
+		return "", fmt.Errorf("could not parse program: %w", err)
+	}
+
+	// Find statements that start a line, and the comments for each node.
+	// Nested statements can start at the same position (e.g., `a` and `a && b`),
+	// so we keep track of statements by where they start.
+	type nodeCmts struct {
+		node syntax.Node
+		cmts []string
+	}
+	stmts := []*syntax.Stmt{}
+	nodes := []nodeCmts{}
+	syntax.Walk(pp, func(node syntax.Node) bool {
+		if x, ok := node.(*syntax.Stmt); ok {
+			pos := int(x.Pos().Offset())
+			lineStart := strings.LastIndexByte(src[:pos], '\n') + 1
+			if strings.TrimSpace(src[lineStart:pos]) == "" {
+				stmts = append(stmts, x)
+			}
+		}
+		if node != nil {
+			if cmts := cmtNode(node); len(cmts) > 0 {
+				nodes = append(nodes, nodeCmts{node, cmts})
+			}
+		}
+		return true
+	})
+
+	// Each node belongs to the innermost statement that contains it or,
+	// failing that, the last statement that starts before it.
+	stmtCmts := map[int][]string{}
+	seen := map[int]map[string]bool{}
+	for _, nc := range nodes {
+		var stmt *syntax.Stmt
+		for _, st := range stmts {
+			if st.Pos().Offset() > nc.node.Pos().Offset() {
+				break
+			}
+			if st.End().Offset() >= nc.node.End().Offset() || stmt == nil || stmt.End().Offset() < nc.node.End().Offset() {
+				stmt = st
+			}
+		}
+		if stmt == nil {
+			continue
+		}
+		pos := int(stmt.Pos().Offset())
+		if seen[pos] == nil {
+			seen[pos] = map[string]bool{}
+		}
+		for _, cmt := range nc.cmts {
+			if !seen[pos][cmt] {
+				seen[pos][cmt] = true
+				stmtCmts[pos] = append(stmtCmts[pos], cmt)
+			}
+		}
+	}
+
+	chgs := []change{}
+	for pos, cmts := range stmtCmts {
+		idtStr := src[strings.LastIndexByte(src[:pos], '\n')+1 : pos]
+		chgs = append(chgs, change{pos, pos, strings.Join(cmts, "\n"+idtStr) + "\n" + idtStr})
+	}
+
+	return modProg(src, chgs), nil
+}
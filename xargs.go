@@ -0,0 +1,124 @@
+package sol
+
+import (
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Short xargs options, by whether they take a value. Options in
+// `xargsOptValOpts` only take a value when it's attached (e.g., `-i{}`).
+var (
+	xargsBoolOpts   = "0oprtx"
+	xargsValOpts    = "EILPadns"
+	xargsOptValOpts = "eil"
+)
+
+// Long xargs options that take a separate value (e.g., `--max-args 2`).
+// Options not listed here either take no value or only take an attached one
+// (e.g., `--replace=%`).
+var xargsLongValOpts = []string{
+	"--arg-file",
+	"--delimiter",
+	"--max-args",
+	"--max-chars",
+	"--max-procs",
+	"--process-slot-var",
+}
+
+// The arguments of an xargs invocation. Each index points into the command's
+// arguments.
+type xargsArgs struct {
+	Opts []int  // start of each option (and its value, if any)
+	Util int    // the utility that xargs runs; 0 if none (i.e., `echo`)
+	Rpl  string // replacement string set with `-I`, `-i`, or `--replace`
+}
+
+func parseXargs(x *syntax.CallExpr) xargsArgs {
+	xa := xargsArgs{}
+	a := 1
+	for ; a < len(x.Args); a++ {
+		val, _ := wordVal(x.Args[a])
+		if val == "--" {
+			a++
+			break
+		}
+		if !strings.HasPrefix(val, "-") || val == "-" {
+			break
+		}
+		xa.Opts = append(xa.Opts, a)
+
+		// Long options, e.g., `--max-args 2`, `--max-args=2`, or `--replace`.
+		if strings.HasPrefix(val, "--") {
+			name, optVal, attached := strings.Cut(val, "=")
+			if name == "--replace" {
+				xa.Rpl = "{}"
+				if attached {
+					xa.Rpl = optVal
+				}
+			}
+			if attached {
+				continue
+			}
+			for _, opt := range xargsLongValOpts {
+				if name == opt && a+1 < len(x.Args) {
+					a++
+					break
+				}
+			}
+			continue
+		}
+
+		// Clustered short options, e.g., `-0n 2`, `-P4`, or `-rI{}`.
+		opts := val[1:]
+		for o := 0; o < len(opts); o++ {
+			opt := opts[o]
+			if strings.IndexByte(xargsBoolOpts, opt) >= 0 {
+				continue
+			}
+			rest := opts[o+1:]
+			if strings.IndexByte(xargsOptValOpts, opt) >= 0 {
+				if opt == 'i' {
+					xa.Rpl = "{}"
+					if rest != "" {
+						xa.Rpl = rest
+					}
+				}
+				break
+			}
+			if strings.IndexByte(xargsValOpts, opt) >= 0 {
+				if rest == "" && a+1 < len(x.Args) {
+					a++
+					rest, _ = wordVal(x.Args[a])
+				}
+				if opt == 'I' {
+					xa.Rpl = rest
+				}
+				break
+			}
+		}
+	}
+
+	if a < len(x.Args) {
+		xa.Util = a
+	}
+
+	return xa
+}
+
+// A placeholder that the shell parser always treats as part of a plain
+// literal.
+const rplPlaceholder = "SOLRPLPLACEHOLDER"
+
+// Swaps a replacement string (e.g., `xargs -I %`) in a command string for a
+// placeholder before formatting, so that the formatter can never split the
+// replacement string from the word that it's part of (or parse it as shell
+// syntax, like `xargs -I '|'`). Returns a function that swaps it back.
+func protectRpl(cmdStr string, rpl string) (string, func(string) string) {
+	if rpl == "" || !strings.Contains(cmdStr, rpl) || strings.Contains(cmdStr, rplPlaceholder) {
+		return cmdStr, func(s string) string { return s }
+	}
+	return strings.ReplaceAll(cmdStr, rpl, rplPlaceholder), func(s string) string {
+		return strings.ReplaceAll(s, rplPlaceholder, rpl)
+	}
+}
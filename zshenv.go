@@ -0,0 +1,137 @@
+package sol
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type zshBackend struct{}
+
+// Returns the directory that zsh reads the user's dotfiles from.
+func zshDotDir() string {
+	if dir := os.Getenv("ZDOTDIR"); dir != "" {
+		return dir
+	}
+	home, _ := os.UserHomeDir()
+	return home
+}
+
+// Returns the files that an interactive (non-login) zsh sources, besides
+// the global zshenv, which zsh always sources first.
+func zshInteractiveRcFiles() []string {
+	files := []string{}
+	dir := zshDotDir()
+	if dir != "" {
+		files = append(files, filepath.Join(dir, ".zshenv"))
+	}
+	files = append(files, "/etc/zsh/zshrc", "/etc/zshrc")
+	if dir != "" {
+		files = append(files, filepath.Join(dir, ".zshrc"))
+	}
+	return files
+}
+
+func (zshBackend) RcFiles() []string {
+	files := []string{"/etc/zsh/zshenv", "/etc/zshenv", "/etc/zsh/zprofile", "/etc/zprofile", "/etc/zsh/zlogin", "/etc/zlogin"}
+	files = append(files, zshInteractiveRcFiles()...)
+	if dir := zshDotDir(); dir != "" {
+		files = append(files, filepath.Join(dir, ".zprofile"), filepath.Join(dir, ".zlogin"))
+	}
+	return files
+}
+
+// Returns the body of each zsh function. A function that uses syntax that
+// only zsh understands is kept as zsh printed it.
+func getZshFuncDefs(funcDefs string) map[string]string {
+	fds := map[string]string{}
+	name := ""
+	def := []string{}
+	flush := func() {
+		if name == "" {
+			return
+		}
+		defStr := strings.Join(def, "\n")
+		parsed, err := getFuncDefs(defStr)
+		if body, ok := parsed[name]; err == nil && ok {
+			fds[name] = body
+		} else {
+			log.Debugf("could not parse zsh function %s: %v", name, err)
+			fds[name] = defStr
+		}
+		name, def = "", []string{}
+	}
+	for _, line := range strings.Split(funcDefs, "\n") {
+		if after, ok := strings.CutPrefix(line, envFuncMarker); ok {
+			flush()
+			name = after
+			continue
+		}
+		def = append(def, line)
+	}
+	flush()
+	return fds
+}
+
+func (zshBackend) Env() (*ShellEnv, error) {
+	env := &ShellEnv{}
+
+	// With `-f`, zsh only sources the global zshenv, so that we can source
+	// the rest of the dotfiles ourselves and tell them apart.
+	secs, err := captureEnv(envShellCmd{
+		Shell:   "zsh",
+		Args:    []string{"-f", "-i", "-c"},
+		RcFiles: zshInteractiveRcFiles(),
+	}, []envSection{
+		{"types", "whence -wm '*'"},
+		{"alias-defs", "alias -rL"},
+		{"builtins", "print -rl -- ${(k)builtins}"},
+		{"func-defs", `for f in ${(ok)functions}; do print -r -- "` + envFuncMarker + `$f"; functions -- "$f"; done`},
+		{"keywords", "print -rl -- ${(k)reswords}"},
+		{"vars", "print -rl -- ${(k)parameters}"},
+		{"var-defs", "typeset -pm '[[:alpha:]_]*'"},
+		{"path", `print -r -- "$PATH"`},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Each line is like `ll: alias` or `ls: command`.
+	env.Aliases = []string{}
+	env.Funcs = []string{}
+	for _, line := range envList(secs["types"]) {
+		name, typ, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+		switch typ {
+		case "alias":
+			env.Aliases = append(env.Aliases, name)
+		case "function":
+			env.Funcs = append(env.Funcs, name)
+		}
+	}
+	env.Builtins = envList(secs["builtins"])
+	env.Keywords = envList(secs["keywords"])
+	env.Vars = envList(secs["vars"])
+
+	env.AliasDefs, err = getAliasDefs(secs["alias-defs"])
+	if err != nil {
+		return nil, err
+	}
+	env.FuncDefs = getZshFuncDefs(secs["func-defs"])
+
+	// zsh prints some variables in ways that we can't parse (e.g., PATH is
+	// tied to the `path` array), so we don't insist on them.
+	env.VarDefs, err = getVarDefs(secs["var-defs"])
+	if err != nil {
+		log.Warningf("could not get zsh var defs: %v", err)
+		env.VarDefs = map[string]string{}
+	}
+	env.VarDefs["PATH"] = secs["path"]
+	env.Paths = strings.Split(secs["path"], ":")
+
+	return env, nil
+}